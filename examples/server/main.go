@@ -2,37 +2,24 @@ package main
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"go.uber.org/zap"
 
 	"gitlab.com/gobl/gobl/pkg/bootstrap"
-	"gitlab.com/gobl/gobl/pkg/service"
 
 	"gitlab.com/gobl/gobl/pkg/cmd"
 	"gitlab.com/gobl/gobl/pkg/logger"
 )
 
 type serverState struct {
-	service.State
 	notifyCh chan struct{}
 	cancel   context.CancelFunc
 }
 
-var (
-	st                    *serverState
-	ErrInvalidServerState = errors.New("state is not a server state object")
-)
-
-func initApp(ctx context.Context, store service.State) error {
+func initApp(ctx context.Context, ss *serverState) error {
 	log := logger.Get(logger.ApplicationLogLevel(), logger.ConfiguredLumberjackLogger())
 
-	ss, ok := store.(*serverState)
-	if !ok {
-		return errors.New("store is not a server state object")
-	}
-
 	log.Info("initialising application")
 
 	childCtx, cancel := context.WithCancel(ctx)
@@ -66,12 +53,7 @@ func initApp(ctx context.Context, store service.State) error {
 	return nil
 }
 
-func cleanupApp(state service.State) error {
-	ss, ok := state.(*serverState)
-	if !ok {
-		return ErrInvalidServerState
-	}
-
+func cleanupApp(ss *serverState) error {
 	ss.cancel()
 
 	// we wait for notification that our long running process has cleanly exited
@@ -80,24 +62,12 @@ func cleanupApp(state service.State) error {
 	return nil
 }
 
-type Service struct {
-	service.Service
-}
-
-func NewService() *Service {
-	return &Service{
-		Service: bootstrap.New(),
-	}
-}
-
 func main() {
-	app := NewService().
+	app := bootstrap.NewTyped[*serverState]().
 		AddInitFunc(initApp).
 		AddCleanupFunc(cleanupApp)
 
 	cmd.SetCliProperties("usage", "a short description", "full help text for the cli")
 
-	st = &serverState{}
-
-	cmd.Execute(context.Background(), app, st)
+	cmd.ExecuteTyped(context.Background(), app, &serverState{})
 }
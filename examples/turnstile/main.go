@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,7 +15,6 @@ import (
 	"gitlab.com/gobl/gobl/pkg/cmd"
 	"gitlab.com/gobl/gobl/pkg/fsm"
 	"gitlab.com/gobl/gobl/pkg/logger"
-	"gitlab.com/gobl/gobl/pkg/service"
 )
 
 const (
@@ -24,7 +22,6 @@ const (
 )
 
 type TurnstileState struct {
-	service.State
 	machine            fsm.FSM
 	errCh              chan error
 	incoming           chan fsm.Event
@@ -32,11 +29,7 @@ type TurnstileState struct {
 	cancelErrorHandler context.CancelFunc
 }
 
-func initStateMachine(ctx context.Context, state service.State) error {
-	s, ok := state.(*TurnstileState)
-	if !ok {
-		return errors.New("unexpected state")
-	}
+func initStateMachine(ctx context.Context, s *TurnstileState) error {
 	s.incoming = make(chan fsm.Event)
 	s.machine, s.errCh = fsm.New(uuid.New(), machineName, states.Locked(uuid.New()).
 		WithTransitions(
@@ -62,12 +55,7 @@ func initStateMachine(ctx context.Context, state service.State) error {
 	return nil
 }
 
-func initErrorHandler(ctx context.Context, state service.State) error {
-	s, ok := state.(*TurnstileState)
-	if !ok {
-		return errors.New("unexpected state")
-	}
-
+func initErrorHandler(ctx context.Context, s *TurnstileState) error {
 	errCtx, cancel := context.WithCancel(ctx)
 
 	s.cancelErrorHandler = cancel
@@ -87,11 +75,7 @@ func initErrorHandler(ctx context.Context, state service.State) error {
 	return nil
 }
 
-func runTurnstile(_ context.Context, state service.State) error {
-	s, ok := state.(*TurnstileState)
-	if !ok {
-		return errors.New("unexpected state")
-	}
+func runTurnstile(_ context.Context, s *TurnstileState) error {
 	s.incoming <- events.Push(uuid.New(), "TEST", time.Now().UnixNano())
 	s.incoming <- events.InsertCoin(uuid.New(), "TEST", time.Now().UnixNano())
 	s.incoming <- events.InsertCoin(uuid.New(), "TEST", time.Now().UnixNano())
@@ -100,31 +84,21 @@ func runTurnstile(_ context.Context, state service.State) error {
 	return nil
 }
 
-func cleanupStateMachine(state service.State) error {
-	s, ok := state.(*TurnstileState)
-	if !ok {
-		return errors.New("unexpected state")
-	}
+func cleanupStateMachine(s *TurnstileState) error {
 	s.cancelMachine()
 	return nil
 }
 
-func cleanupErrorHandler(state service.State) error {
-	s, ok := state.(*TurnstileState)
-	if !ok {
-		return errors.New("unexpected state")
-	}
+func cleanupErrorHandler(s *TurnstileState) error {
 	s.cancelErrorHandler()
 	return nil
 }
 
 func main() {
-	app := bootstrap.New().
+	app := bootstrap.NewTyped[*TurnstileState]().
 		AddInitFunc(initStateMachine, initErrorHandler).
 		AddCleanupFunc(cleanupStateMachine, cleanupErrorHandler).
 		WithRunFunc(runTurnstile)
 
-	st := new(TurnstileState)
-
-	cmd.Execute(context.Background(), app, st)
+	cmd.ExecuteTyped(context.Background(), app, new(TurnstileState))
 }
@@ -9,14 +9,15 @@ import (
 //
 //nolint:gochecknoglobals
 var bindings = map[string]string{
-	"version":         "",
-	"service.name":    "",
-	"log.filepath":    "",
-	"log.level":       "",
-	"log.max-size":    "",
-	"log.max-backups": "",
-	"log.max-age":     "",
-	"log.compress":    "",
+	"version":                       "",
+	"service.name":                  "",
+	"service.shutdown-grace-period": "",
+	"log.filepath":                  "",
+	"log.level":                     "",
+	"log.max-size":                  "",
+	"log.max-backups":               "",
+	"log.max-age":                   "",
+	"log.compress":                  "",
 }
 
 // BindEnvVars binds any environment variables that have been defined with the
@@ -2,17 +2,26 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
 
-	"gitlab.com/gobl/gobl/pkg/cmd"
 	"gitlab.com/gobl/gobl/pkg/property"
 	"gitlab.com/gobl/gobl/pkg/service"
 )
 
+var (
+	_ service.Service           = (*bootstrap)(nil)
+	_ service.ComponentAdder    = (*bootstrap)(nil)
+	_ service.ReadinessReporter = (*bootstrap)(nil)
+	_ service.ContextCleaner    = (*bootstrap)(nil)
+)
+
 type bootstrap struct {
 	initFunctions    []service.InitFunc
 	cleanupFunctions []service.CleanupFunc
 	runFunc          service.RunFunc
 	properties       property.Properties
+	lifecycle        *service.Lifecycle
+	readiness        service.Readiness
 }
 
 // New creates a new instance of the bootstrap server application
@@ -24,11 +33,13 @@ func New() service.Service {
 		cleanupFunctions: make([]service.CleanupFunc, 0),
 		runFunc:          nil,
 		properties:       make(property.Properties),
+		lifecycle:        service.NewLifecycle(),
 	}
 }
 
 // Init is called when the application starts and executes the initialisation functions
-// that have been added to the application
+// that have been added to the application, then starts the components in the order of their dependencies
+// and reports the service as ready
 func (a *bootstrap) Init(ctx context.Context, state service.State) error {
 	for _, f := range a.initFunctions {
 		if err := f(ctx, state); err != nil {
@@ -36,6 +47,14 @@ func (a *bootstrap) Init(ctx context.Context, state service.State) error {
 		}
 	}
 
+	if err := a.lifecycle.Start(ctx, state); err != nil {
+		return err
+	}
+
+	if a.readiness != nil {
+		a.readiness.SetReady(true)
+	}
+
 	return nil
 }
 
@@ -45,17 +64,28 @@ func (a *bootstrap) AddInitFunc(initFuncs ...service.InitFunc) service.Service {
 	return a
 }
 
-// Cleanup is called when the application terminates. It executes all cleanup functions
-// that have been added to the application attempting to ensure the application can
-// terminate gracefully.
+// Cleanup is called when the application terminates. It reports the service as not ready, stops the
+// components in the reverse order they were started and then executes all cleanup functions that have
+// been added to the application attempting to ensure the application can terminate gracefully. Every
+// cleanup function is executed even if another one fails, and all the errors are returned joined together.
 func (a *bootstrap) Cleanup(state service.State) error {
+	return a.CleanupContext(context.Background(), state)
+}
+
+// CleanupContext cleans up the application in the same way as Cleanup, stopping the components with the
+// context so that stopping them is abandoned once the context is done
+func (a *bootstrap) CleanupContext(ctx context.Context, state service.State) error {
+	if a.readiness != nil {
+		a.readiness.SetReady(false)
+	}
+
+	errs := []error{a.lifecycle.Stop(ctx, state)}
+
 	for _, f := range a.cleanupFunctions {
-		if err := f(state); err != nil {
-			return err
-		}
+		errs = append(errs, f(state))
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // AddCleanupFunc adds cleanup function that will be run when the application attempts a graceful shutdown
@@ -64,16 +94,24 @@ func (a *bootstrap) AddCleanupFunc(fns ...service.CleanupFunc) service.Service {
 	return a
 }
 
-// SetProperties adds properties that may be needed by the application
-func (a *bootstrap) SetProperties(properties property.Properties) error {
-	a.properties = properties
-
-	usage := a.properties.GetOrElse(property.KeyUsage, property.Empty()).NonEmptyString(cmd.Usage)
-	shortDesc := a.properties.GetOrElse(property.KeyShortDesc, property.Empty()).NonEmptyString(cmd.ShortDescription)
-	longDesc := a.properties.GetOrElse(property.KeyLongDesc, property.Empty()).NonEmptyString(cmd.LongDescription)
+// AddComponent adds components with dependencies that are started once the initialisation functions
+// have run and stopped before the cleanup functions run
+func (a *bootstrap) AddComponent(components ...service.Component) service.Service {
+	a.lifecycle.Add(components...)
+	return a
+}
 
-	cmd.SetCliProperties(usage, shortDesc, longDesc)
+// WithReadiness sets the readiness that is reported as ready once the service has been initialised and
+// as not ready when it starts cleaning up, so that traffic stops being routed to it before it shuts down
+func (a *bootstrap) WithReadiness(r service.Readiness) service.Service {
+	a.readiness = r
+	return a
+}
 
+// SetProperties adds properties that may be needed by the application. The usage, short description and
+// long description properties are used by the cmd package for the help information of the command line.
+func (a *bootstrap) SetProperties(properties property.Properties) error {
+	a.properties = properties
 	return nil
 }
 
@@ -104,3 +142,9 @@ func (a *bootstrap) GetProperty(name string) (property.Property, error) {
 	}
 	return p, nil
 }
+
+// NewTyped creates a new instance of the bootstrap server application whose functions receive the
+// state as S. Pass it to cmd.ExecuteTyped with a state of type S to run it.
+func NewTyped[S any]() service.TypedService[S] {
+	return service.Typed[S](New())
+}
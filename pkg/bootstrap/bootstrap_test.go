@@ -0,0 +1,126 @@
+package bootstrap_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/bootstrap"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+func TestBootstrap_Cleanup(t *testing.T) {
+	t.Run("Cleanup should run every cleanup function and join the errors", func(t *testing.T) {
+		errA, errB := errors.New("a"), errors.New("b")
+		ran := 0
+
+		cleanup := func(err error) service.CleanupFunc {
+			return func(service.State) error {
+				ran++
+				return err
+			}
+		}
+
+		app := bootstrap.New().AddCleanupFunc(cleanup(errA), cleanup(nil), cleanup(errB))
+
+		err := app.Cleanup(nil)
+		require.ErrorIs(t, err, errA)
+		require.ErrorIs(t, err, errB)
+		assert.Equal(t, 3, ran)
+	})
+
+	t.Run("Cleanup should stop the components before running the cleanup functions", func(t *testing.T) {
+		var calls []string
+
+		record := func(call string) service.LifecycleFunc {
+			return func(context.Context, service.State) error {
+				calls = append(calls, call)
+				return nil
+			}
+		}
+
+		app := bootstrap.New().
+			AddInitFunc(func(context.Context, service.State) error {
+				calls = append(calls, "init")
+				return nil
+			}).
+			AddCleanupFunc(func(service.State) error {
+				calls = append(calls, "cleanup")
+				return nil
+			})
+
+		require.NoError(t, service.AddComponent(app,
+			service.Component{Name: "api", DependsOn: []string{"db"}, Start: record("start api"), Stop: record("stop api")},
+			service.Component{Name: "db", Start: record("start db"), Stop: record("stop db")},
+		))
+
+		require.NoError(t, app.Init(context.Background(), nil))
+		require.NoError(t, app.Cleanup(nil))
+
+		assert.Equal(t, []string{"init", "start db", "start api", "stop api", "stop db", "cleanup"}, calls)
+	})
+
+	t.Run("CleanupContext should stop the components with the context", func(t *testing.T) {
+		type key struct{}
+
+		var got any
+
+		app := bootstrap.New()
+		require.NoError(t, service.AddComponent(app, service.Component{
+			Name: "db",
+			Stop: func(ctx context.Context, _ service.State) error {
+				got = ctx.Value(key{})
+				return nil
+			},
+		}))
+
+		ctx := context.WithValue(context.Background(), key{}, "cleanup")
+
+		require.NoError(t, app.Init(ctx, nil))
+		require.NoError(t, app.(service.ContextCleaner).CleanupContext(ctx, nil))
+		assert.Equal(t, "cleanup", got)
+	})
+}
+
+type readiness struct {
+	ready bool
+}
+
+func (r *readiness) SetReady(ready bool) {
+	r.ready = ready
+}
+
+func TestBootstrap_Readiness(t *testing.T) {
+	r := &readiness{}
+	app := bootstrap.New()
+	require.NoError(t, service.WithReadiness(app, r))
+
+	t.Run("Init should report the service as ready", func(t *testing.T) {
+		require.NoError(t, app.Init(context.Background(), nil))
+		assert.True(t, r.ready)
+	})
+
+	t.Run("Cleanup should report the service as not ready before the cleanup functions run", func(t *testing.T) {
+		app.AddCleanupFunc(func(service.State) error {
+			assert.False(t, r.ready)
+			return nil
+		})
+
+		require.NoError(t, app.Cleanup(nil))
+		assert.False(t, r.ready)
+	})
+
+	t.Run("Init should not report the service as ready if it fails", func(t *testing.T) {
+		r := &readiness{}
+		errFailed := errors.New("failed")
+
+		app := bootstrap.New().AddInitFunc(func(context.Context, service.State) error { return errFailed })
+		require.NoError(t, service.WithReadiness(app, r))
+
+		require.ErrorIs(t, app.Init(context.Background(), nil), errFailed)
+		assert.False(t, r.ready)
+	})
+}
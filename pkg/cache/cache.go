@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"context"
+	"hash/maphash"
 	"sync"
 	"time"
 )
@@ -9,123 +11,227 @@ const (
 	NoExpiry = time.Duration(0)
 )
 
-// Cache is a simple generic cache that can be used to store any type of data
+// Cache is a simple generic cache that can be used to store any type of data with string keys.
+// The cache grows without bound unless a capacity is set with WithMaxEntries or WithMaxCost,
+// in which case items are evicted according to the eviction policy.
+// The items are partitioned by key between the shards set with WithShards, each with its own lock.
 type Cache[T any] struct {
-	mu      sync.Mutex
-	data    map[string]item[T]
-	options options
+	Keyed[string, T]
 }
 
-type item[T any] struct {
-	value  T
-	expiry int64
+// Keyed is a cache with keys of any comparable type, e.g. integer IDs or structs, so that the keys
+// do not need to be formatted as strings. It supports the same options as Cache.
+type Keyed[K comparable, V any] struct {
+	shards    []*shard[K, V]
+	seed      maphash.Seed
+	options   options
+	callbacks callbacks[K, V]
+	// stopJanitor stops the janitor goroutine, which closes janitorDone when it returns
+	stopJanitor context.CancelFunc
+	janitorDone chan struct{}
+	closeOnce   sync.Once
+	// loads coalesces the loads of GetOrLoad, errors records the failed loads for the error TTL and
+	// expired errors are swept once there are errSweepAt of them, see storeError
+	loads      flights[K, V]
+	errMu      sync.Mutex
+	errors     map[K]loadError
+	errSweepAt int
 }
 
-// New returns a new cache of the given type
+// New returns a new cache of the given type. It panics with ErrOptionType if it is given a typed option,
+// such as WithSizer, for keys that are not strings or values that are not of the type.
 func New[T any](opts ...Option) *Cache[T] {
+	c := new(Cache[T])
+	c.init(opts)
+
+	return c
+}
+
+// NewKeyed returns a new cache with keys and values of the given types. It panics with ErrOptionType if
+// it is given a typed option, such as WithSizer, for keys or values of other types.
+func NewKeyed[K comparable, V any](opts ...Option) *Keyed[K, V] {
+	c := new(Keyed[K, V])
+	c.init(opts)
+
+	return c
+}
+
+// init applies the options to the new cache and starts the janitor
+func (c *Keyed[K, V]) init(opts []Option) {
 	def := defaultOptions()
 	for _, o := range opts {
 		o(&def)
 	}
 
-	return &Cache[T]{
-		mu:      sync.Mutex{},
-		data:    make(map[string]item[T]),
-		options: def,
+	n := shardCount(&def)
+
+	c.shards = make([]*shard[K, V], n)
+	c.seed = maphash.MakeSeed()
+	c.options = def
+	c.callbacks = newCallbacks[K, V](&def)
+	c.loads = flights[K, V]{calls: make(map[K]*flight[V])}
+	c.errors = make(map[K]loadError)
+
+	for i := range c.shards {
+		c.shards[i] = newShard[K, V](&c.options, &c.callbacks, i, n)
+	}
+
+	if def.cleanupInterval > 0 {
+		ctx, cancel := context.WithCancel(def.ctx)
+		c.stopJanitor = cancel
+		c.janitorDone = make(chan struct{})
+
+		go c.janitor(ctx, def.cleanupInterval)
 	}
 }
 
-// Get returns the value for the given key if it exists and has not expired
-func (c *Cache[T]) Get(key string) (T, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// shard returns the shard that holds the key
+func (c *Keyed[K, V]) shard(key K) *shard[K, V] {
+	return c.shards[c.shardIndex(key)]
+}
+
+func (c *Keyed[K, V]) shardIndex(key K) int {
+	if len(c.shards) == 1 {
+		return 0
+	}
 
-	var v T
-	i, ok := c.data[key]
-	if !ok {
-		return v, false
+	return int(hashKey(c.seed, key) % uint64(len(c.shards)))
+}
+
+// group splits the keys by the shard that holds them, so that bulk operations lock each shard once
+func (c *Keyed[K, V]) group(keys []K) [][]K {
+	if len(c.shards) == 1 {
+		return [][]K{keys}
 	}
 
-	if i.expiry != 0 && i.expiry < time.Now().UnixNano() {
-		delete(c.data, key)
-		return v, false
+	groups := make([][]K, len(c.shards))
+	for _, k := range keys {
+		i := c.shardIndex(k)
+		groups[i] = append(groups[i], k)
 	}
 
-	return i.value, true
+	return groups
+}
+
+// Get returns the value for the given key if it exists and has not expired
+func (c *Keyed[K, V]) Get(key K) (V, bool) {
+	i, ok := c.shard(key).get(key)
+	return i.value, ok
+}
+
+// GetMany returns the values for the keys that exist and have not expired, keys that are not
+// in the cache are not in the map
+func (c *Keyed[K, V]) GetMany(keys ...K) map[K]V {
+	values := make(map[K]V, len(keys))
+
+	for i, group := range c.group(keys) {
+		if len(group) > 0 {
+			c.shards[i].getMany(group, values)
+		}
+	}
+
+	return values
 }
 
 // Set sets the value for the given key with the default expiry
-func (c *Cache[T]) Set(key string, value T) {
+func (c *Keyed[K, V]) Set(key K, value V) {
 	c.SetWithExpiry(key, value, c.options.expiry)
 }
 
-// SetWithExpiry sets the value for the given key with an expiry
-func (c *Cache[T]) SetWithExpiry(key string, value T, expiry time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// SetWithExpiry sets the value for the given key with an expiry.
+// If the cache is full, items are evicted to make room for the value. The value is not
+// added if the eviction policy does not admit it, or if it costs more than the maximum cost.
+func (c *Keyed[K, V]) SetWithExpiry(key K, value V, expiry time.Duration) {
+	c.written(key)
+	c.shard(key).set(key, value, expiry)
+}
+
+// SetMany sets the values for the keys in the map with the default expiry
+func (c *Keyed[K, V]) SetMany(values map[K]V) {
+	c.SetManyWithExpiry(values, c.options.expiry)
+}
 
-	if expiry == NoExpiry {
-		c.data[key] = item[T]{
-			value:  value,
-			expiry: expiry.Nanoseconds(),
-		}
-		return
+// SetManyWithExpiry sets the values for the keys in the map with an expiry, in the same way as SetWithExpiry
+func (c *Keyed[K, V]) SetManyWithExpiry(values map[K]V, expiry time.Duration) {
+	keys := make([]K, 0, len(values))
+	for k := range values {
+		c.written(k)
+		keys = append(keys, k)
 	}
 
-	c.data[key] = item[T]{
-		value:  value,
-		expiry: time.Now().Add(expiry).UnixNano(),
+	for i, group := range c.group(keys) {
+		if len(group) > 0 {
+			c.shards[i].setMany(group, values, expiry)
+		}
 	}
 }
 
 // Delete deletes the value for the given key
-func (c *Cache[T]) Delete(key string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Keyed[K, V]) Delete(key K) {
+	c.written(key)
+	c.shard(key).delete(key)
+}
 
-	delete(c.data, key)
+// DeleteMany deletes the values for the keys
+func (c *Keyed[K, V]) DeleteMany(keys ...K) {
+	for _, k := range keys {
+		c.written(k)
+	}
+
+	for i, group := range c.group(keys) {
+		if len(group) > 0 {
+			c.shards[i].deleteMany(group)
+		}
+	}
 }
 
 // Clear clears the cache
-func (c *Cache[T]) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Keyed[K, V]) Clear() {
+	c.loads.invalidateAll()
+	c.clearErrors()
 
-	c.data = make(map[string]item[T])
+	for _, s := range c.shards {
+		s.clear()
+	}
 }
 
 // Len returns the number of items in the cache
-func (c *Cache[T]) Len() int {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Keyed[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.len()
+	}
 
-	return len(c.data)
+	return n
 }
 
-// Keys returns the keys in the cache
-func (c *Cache[T]) Keys() []string {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	keys := make([]string, 0, len(c.data))
-	for k := range c.data {
-		keys = append(keys, k)
+// Cost returns the total cost of the items in the cache, which is the number of items
+// unless a sizer has been set with WithSizer
+func (c *Keyed[K, V]) Cost() int64 {
+	var cost int64
+	for _, s := range c.shards {
+		cost += s.totalCost()
 	}
 
-	return keys
+	return cost
 }
 
-// Flush removes expired items from the cache
-func (c *Cache[T]) Flush() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Keys returns the keys in the cache, including the keys of items that have expired but have not
+// been removed yet. Use All to only iterate over the items that have not expired.
+func (c *Keyed[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Len())
+	for _, s := range c.shards {
+		keys = s.keys(keys)
+	}
 
-	now := time.Now().UnixNano()
+	return keys
+}
 
-	for k := range c.data {
-		if c.data[k].expiry == 0 || now < c.data[k].expiry {
-			continue
-		}
-		delete(c.data, k)
+// Flush removes expired items, and expired errors cached by GetOrLoad, from the cache
+func (c *Keyed[K, V]) Flush() {
+	for _, s := range c.shards {
+		s.flush()
 	}
+
+	c.flushErrors()
 }
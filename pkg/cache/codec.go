@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNotBinaryMarshaler is returned by BinaryCodec when the value does not implement
+// encoding.BinaryMarshaler, or a pointer to it does not implement encoding.BinaryUnmarshaler
+var ErrNotBinaryMarshaler = errors.New("value does not implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler")
+
+// Codec serialises the values stored in a distributed cache
+type Codec interface {
+	// Marshal serialises the value
+	Marshal(v any) ([]byte, error)
+	// Unmarshal deserialises the data into the value pointed to by v
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec serialises values as JSON
+type JSONCodec struct{}
+
+// Marshal serialises the value as JSON
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal deserialises the JSON into the value pointed to by v
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec serialises values with encoding/gob
+type GobCodec struct{}
+
+// Marshal serialises the value with gob
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal deserialises the gob data into the value pointed to by v
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// BinaryCodec serialises values that implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler,
+// e.g. property.Properties
+type BinaryCodec struct{}
+
+// Marshal serialises the value with its MarshalBinary method
+func (BinaryCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotBinaryMarshaler, v)
+	}
+
+	return m.MarshalBinary()
+}
+
+// Unmarshal deserialises the data with the UnmarshalBinary method of the value pointed to by v
+func (BinaryCodec) Unmarshal(data []byte, v any) error {
+	u, ok := v.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotBinaryMarshaler, v)
+	}
+
+	return u.UnmarshalBinary(data)
+}
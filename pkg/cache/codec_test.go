@@ -0,0 +1,56 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+	"gitlab.com/gobl/gobl/pkg/property"
+)
+
+type codecValue struct {
+	Foo string
+	Bar int
+}
+
+func TestCodecs(t *testing.T) {
+	value := codecValue{Foo: "foo", Bar: 100}
+
+	for name, codec := range map[string]cache.Codec{
+		"JSONCodec": cache.JSONCodec{},
+		"GobCodec":  cache.GobCodec{},
+	} {
+		t.Run(name+" should round trip a value", func(t *testing.T) {
+			data, err := codec.Marshal(value)
+			require.NoError(t, err)
+
+			var got codecValue
+			require.NoError(t, codec.Unmarshal(data, &got))
+			assert.Equal(t, value, got)
+		})
+	}
+
+	t.Run("BinaryCodec should round trip properties", func(t *testing.T) {
+		props := property.NewProperties().
+			Add(property.StringProperty("name", "gobl")).
+			Add(property.IntProperty("count", 3))
+
+		codec := cache.BinaryCodec{}
+		data, err := codec.Marshal(props)
+		require.NoError(t, err)
+
+		var got property.Properties
+		require.NoError(t, codec.Unmarshal(data, &got))
+		assert.Equal(t, props, got)
+	})
+
+	t.Run("BinaryCodec should reject values that are not binary marshalers", func(t *testing.T) {
+		codec := cache.BinaryCodec{}
+
+		_, err := codec.Marshal(value)
+		assert.ErrorIs(t, err, cache.ErrNotBinaryMarshaler)
+		assert.ErrorIs(t, codec.Unmarshal([]byte{}, &value), cache.ErrNotBinaryMarshaler)
+	})
+}
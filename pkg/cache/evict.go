@@ -0,0 +1,74 @@
+package cache
+
+// EvictionReason is the reason an item was removed from the cache
+type EvictionReason int
+
+const (
+	// ReasonExpired is used when the item was removed because it expired
+	ReasonExpired EvictionReason = iota
+	// ReasonCapacity is used when the item was evicted to make room for another item
+	ReasonCapacity
+	// ReasonDeleted is used when the item was removed with Delete
+	ReasonDeleted
+	// ReasonReplaced is used when the item was replaced by a new value for the same key
+	ReasonReplaced
+	// ReasonCleared is used when the item was removed with Clear
+	ReasonCleared
+)
+
+// String returns the name of the eviction reason
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyedEvictFn is called with an item that has been removed from a Keyed cache and the reason it was removed
+type KeyedEvictFn[K comparable, V any] func(key K, value V, reason EvictionReason)
+
+// EvictFn is called with an item that has been removed from the cache and the reason it was removed
+type EvictFn[T any] func(key string, value T, reason EvictionReason)
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+}
+
+// evicted records the removed item so the callbacks can be called once the lock has been released
+func (s *shard[K, V]) evicted(key K, value V, reason EvictionReason) {
+	if s.callbacks.onEvict == nil && s.callbacks.onExpire == nil {
+		return
+	}
+
+	s.evictions = append(s.evictions, eviction[K, V]{key: key, value: value, reason: reason})
+}
+
+// unlock releases the lock and then calls the callbacks for the items removed while it was held,
+// so that callbacks can use the cache and slow callbacks do not block it
+func (s *shard[K, V]) unlock() {
+	evictions := s.evictions
+	s.evictions = nil
+	s.mu.Unlock()
+
+	for _, e := range evictions {
+		if s.callbacks.onEvict != nil {
+			s.callbacks.onEvict(e.key, e.value, e.reason)
+		}
+
+		if s.callbacks.onExpire != nil && e.reason == ReasonExpired {
+			s.callbacks.onExpire(e.key, e.value, e.reason)
+		}
+	}
+}
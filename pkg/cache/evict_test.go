@@ -0,0 +1,132 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+type evicted struct {
+	key    string
+	value  int
+	reason cache.EvictionReason
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	evicted []evicted
+}
+
+func (r *recorder) record(key string, value int, reason cache.EvictionReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evicted = append(r.evicted, evicted{key: key, value: value, reason: reason})
+}
+
+func (r *recorder) take() []evicted {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.evicted
+	r.evicted = nil
+
+	return e
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	r := &recorder{}
+	c := cache.New[int](cache.WithMaxEntries(2), cache.WithOnEvict(r.record))
+
+	t.Run("OnEvict should be called when an item is evicted to make room", func(t *testing.T) {
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Set("c", 3)
+
+		assert.Equal(t, []evicted{{"a", 1, cache.ReasonCapacity}}, r.take())
+	})
+
+	t.Run("OnEvict should be called when an item is replaced", func(t *testing.T) {
+		c.Set("b", 20)
+
+		assert.Equal(t, []evicted{{"b", 2, cache.ReasonReplaced}}, r.take())
+	})
+
+	t.Run("OnEvict should be called when an item is deleted", func(t *testing.T) {
+		c.Delete("b")
+
+		assert.Equal(t, []evicted{{"b", 20, cache.ReasonDeleted}}, r.take())
+	})
+
+	t.Run("OnEvict should be called when an expired item is requested", func(t *testing.T) {
+		c.SetWithExpiry("d", 4, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		_, ok := c.Get("d")
+		require.False(t, ok)
+
+		assert.Equal(t, []evicted{{"d", 4, cache.ReasonExpired}}, r.take())
+	})
+
+	t.Run("OnEvict should be called for every item when the cache is cleared", func(t *testing.T) {
+		c.Clear()
+
+		assert.Equal(t, []evicted{{"c", 3, cache.ReasonCleared}}, r.take())
+	})
+
+	t.Run("OnEvict should be able to use the cache", func(t *testing.T) {
+		var c *cache.Cache[int]
+		c = cache.New[int](cache.WithOnEvict(func(key string, value int, _ cache.EvictionReason) {
+			c.Set(key+"-evicted", value)
+		}))
+
+		c.Set("a", 1)
+		c.Delete("a")
+
+		v, ok := c.Get("a-evicted")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	})
+}
+
+func TestCache_Janitor(t *testing.T) {
+	t.Run("The janitor should remove expired items in the background", func(t *testing.T) {
+		r := &recorder{}
+		c := cache.New[int](cache.WithCleanupInterval(5*time.Millisecond), cache.WithOnExpire(r.record))
+		defer c.Close()
+
+		c.SetWithExpiry("a", 1, 10*time.Millisecond)
+		c.Set("b", 2)
+
+		assert.Eventually(t, func() bool { return c.Len() == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, []evicted{{"a", 1, cache.ReasonExpired}}, r.take())
+	})
+
+	t.Run("Close should stop the janitor", func(t *testing.T) {
+		c := cache.New[int](cache.WithCleanupInterval(5 * time.Millisecond))
+		c.Close()
+		c.Close()
+
+		c.SetWithExpiry("a", 1, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("The janitor should stop when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := cache.New[int](cache.WithCleanupInterval(5*time.Millisecond), cache.WithContext(ctx))
+		cancel()
+		c.Close()
+
+		c.SetWithExpiry("a", 1, time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+
+		assert.Equal(t, 1, c.Len())
+	})
+}
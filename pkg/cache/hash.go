@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+// hashKey hashes the key with the seed, to pick the shard that holds it and to estimate how often it is used.
+// Keys are hashed by value so that keys that are equal have the same hash: strings, integers and floats directly,
+// and other keys, such as structs, arrays and named types, by the values of their underlying kind.
+func hashKey[K comparable](seed maphash.Seed, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(seed, k)
+	case int:
+		return hashUint(seed, uint64(k))
+	case int8:
+		return hashUint(seed, uint64(k))
+	case int16:
+		return hashUint(seed, uint64(k))
+	case int32:
+		return hashUint(seed, uint64(k))
+	case int64:
+		return hashUint(seed, uint64(k))
+	case uint:
+		return hashUint(seed, uint64(k))
+	case uint8:
+		return hashUint(seed, uint64(k))
+	case uint16:
+		return hashUint(seed, uint64(k))
+	case uint32:
+		return hashUint(seed, uint64(k))
+	case uint64:
+		return hashUint(seed, k)
+	case uintptr:
+		return hashUint(seed, uint64(k))
+	case float32:
+		return hashFloat(seed, float64(k))
+	case float64:
+		return hashFloat(seed, k)
+	default:
+		var h maphash.Hash
+		h.SetSeed(seed)
+		hashValue(&h, reflect.ValueOf(&key).Elem())
+
+		return h.Sum64()
+	}
+}
+
+func hashUint(seed maphash.Seed, v uint64) uint64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+
+	return maphash.Bytes(seed, b[:])
+}
+
+// hashFloat hashes the float by value, -0 is equal to 0 so they have the same hash
+func hashFloat(seed maphash.Seed, v float64) uint64 {
+	return hashUint(seed, floatBits(v))
+}
+
+// floatBits returns the bits of the float, with -0 normalised to 0 as they are equal
+func floatBits(v float64) uint64 {
+	if v == 0 {
+		v = 0
+	}
+
+	return math.Float64bits(v)
+}
+
+// hashValue writes the comparable value to the hash by its underlying kind, so that values that are equal
+// write the same bytes
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			writeUint(h, 1)
+		} else {
+			writeUint(h, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint(h, floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		writeUint(h, floatBits(real(v.Complex())))
+		writeUint(h, floatBits(imag(v.Complex())))
+	case reflect.String:
+		writeUint(h, uint64(v.Len()))
+		_, _ = h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint(h, uint64(v.Pointer()))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			hashValue(h, v.Field(i))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			writeUint(h, 0)
+			return
+		}
+
+		// values of different dynamic types are not equal, so they only need to hash differently most of the time
+		_, _ = h.WriteString(v.Elem().Type().String())
+		hashValue(h, v.Elem())
+	default:
+		// the other kinds are not comparable, so they cannot be part of a key
+	}
+}
+
+func writeUint(h *maphash.Hash, v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	_, _ = h.Write(b[:])
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/logger"
+)
+
+// DefaultInvalidationChannel is the default Redis channel that invalidations are published on
+const DefaultInvalidationChannel = "cache:invalidations"
+
+// invalidation is the message published when keys are invalidated
+type invalidation struct {
+	// Origin is the id of the invalidator that published the message, so it can ignore its own messages
+	Origin uuid.UUID `json:"origin"`
+	// Keys are the invalidated keys, no keys invalidates everything
+	Keys []string `json:"keys"`
+}
+
+// RedisInvalidator broadcasts invalidations using Redis pub/sub
+type RedisInvalidator struct {
+	client  redis.UniversalClient
+	channel string
+	id      uuid.UUID
+}
+
+// NewRedisInvalidator creates an Invalidator that publishes invalidations on the Redis channel.
+// Each replica needs its own invalidator, as an invalidator ignores the messages it published itself.
+func NewRedisInvalidator(client redis.UniversalClient, channel string) *RedisInvalidator {
+	return &RedisInvalidator{
+		client:  client,
+		channel: channel,
+		id:      uuid.New(),
+	}
+}
+
+// Invalidate publishes the keys on the channel
+func (r *RedisInvalidator) Invalidate(ctx context.Context, keys ...string) error {
+	msg, err := json.Marshal(invalidation{Origin: r.id, Keys: keys})
+	if err != nil {
+		return fmt.Errorf("serialising invalidation: %w", err)
+	}
+
+	if err := r.client.Publish(ctx, r.channel, msg).Err(); err != nil {
+		return fmt.Errorf("publishing invalidation: %w", err)
+	}
+
+	return nil
+}
+
+// Listen subscribes to the channel and calls fn with the keys published by other invalidators
+// until the context is done
+func (r *RedisInvalidator) Listen(ctx context.Context, fn func(keys []string)) error {
+	sub := r.client.Subscribe(ctx, r.channel)
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribing to %s: %w", r.channel, err)
+	}
+
+	ch := sub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				logger.Logger().Warn("Ignoring invalid cache invalidation", zap.String("channel", r.channel), zap.Error(err))
+				continue
+			}
+
+			if inv.Origin == r.id {
+				continue
+			}
+
+			fn(inv.Keys)
+		}
+	}
+}
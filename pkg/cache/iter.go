@@ -0,0 +1,28 @@
+package cache
+
+// All returns an iterator over the keys and values in the cache that have not expired, in no
+// particular order. Each shard is copied before its items are yielded, so the cache can be
+// changed while iterating, and items added to a shard once it has been copied are not yielded.
+// Iterating does not count as using the items for the eviction policy or the statistics.
+// The iterator has the same type as iter.Seq2, so it can be ranged over from Go 1.23.
+func (c *Keyed[K, V]) All() func(yield func(K, V) bool) {
+	return func(yield func(K, V) bool) {
+		var items []persisted[K, V]
+
+		for _, s := range c.shards {
+			items = s.snapshot(items[:0])
+
+			for _, i := range items {
+				if !yield(i.Key, i.Value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Range calls fn with each key and value in the cache that has not expired, in the same way as All,
+// until fn returns false
+func (c *Keyed[K, V]) Range(fn func(key K, value V) bool) {
+	c.All()(fn)
+}
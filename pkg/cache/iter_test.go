@@ -0,0 +1,201 @@
+package cache_test
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+type point struct {
+	x, y int
+}
+
+func TestKeyed(t *testing.T) {
+	t.Run("Keyed should use keys of any comparable type", func(t *testing.T) {
+		c := cache.NewKeyed[point, string](cache.WithShards(4))
+		c.Set(point{1, 2}, "a")
+		c.Set(point{2, 1}, "b")
+
+		v, ok := c.Get(point{1, 2})
+		assert.True(t, ok)
+		assert.Equal(t, "a", v)
+		assert.ElementsMatch(t, []point{{1, 2}, {2, 1}}, c.Keys())
+	})
+
+	t.Run("Keyed should find values stored under equal keys that format differently", func(t *testing.T) {
+		type position struct {
+			name string
+			x    float64
+		}
+
+		negativeZero := math.Copysign(0, -1)
+
+		c := cache.NewKeyed[position, int](cache.WithShards(16))
+		for i := 0; i < 100; i++ {
+			c.Set(position{name: strconv.Itoa(i), x: negativeZero}, i)
+		}
+
+		for i := 0; i < 100; i++ {
+			v, ok := c.Get(position{name: strconv.Itoa(i), x: 0})
+			require.True(t, ok, i)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("Keyed should use keys of named types", func(t *testing.T) {
+		type id int
+
+		c := cache.NewKeyed[id, string](cache.WithShards(4))
+		c.Set(id(1), "a")
+
+		v, ok := c.Get(id(1))
+		assert.True(t, ok)
+		assert.Equal(t, "a", v)
+	})
+
+	t.Run("Keyed should support the options with typed keys", func(t *testing.T) {
+		var evicted []int
+
+		c := cache.NewKeyed[int, string](
+			cache.WithMaxCost(4),
+			cache.WithSizer(func(_ int, v string) int64 { return int64(len(v)) }),
+			cache.WithOnEvict(func(k int, _ string, reason cache.EvictionReason) {
+				if reason == cache.ReasonCapacity {
+					evicted = append(evicted, k)
+				}
+			}),
+		)
+
+		c.Set(1, "ab")
+		c.Set(2, "cd")
+		c.Set(3, "ef")
+
+		assert.Equal(t, []int{1}, evicted)
+	})
+
+	t.Run("Keyed should accept named callback types", func(t *testing.T) {
+		var onEvict cache.EvictFn[int] = func(string, int, cache.EvictionReason) {}
+
+		assert.NotPanics(t, func() {
+			cache.New[int](cache.WithOnEvict(onEvict), cache.WithOnExpire(onEvict))
+		})
+	})
+
+	t.Run("Keyed should panic when an option is for other key or value types", func(t *testing.T) {
+		options := map[string]cache.Option{
+			"sizer":     cache.WithSizer(func(string, string) int64 { return 1 }),
+			"on evict":  cache.WithOnEvict(func(string, string, cache.EvictionReason) {}),
+			"on expire": cache.WithOnExpire(func(int, int, cache.EvictionReason) {}),
+		}
+
+		for name, o := range options {
+			func() {
+				defer func() {
+					err, _ := recover().(error)
+					assert.ErrorIs(t, err, cache.ErrOptionType, name)
+				}()
+
+				cache.NewKeyed[int, string](o)
+			}()
+		}
+	})
+
+	t.Run("GetOrLoad should pass the typed key to the loader", func(t *testing.T) {
+		c := cache.NewKeyed[int, int]()
+
+		v, err := c.GetOrLoad(context.Background(), 21, func(_ context.Context, k int) (int, error) {
+			return k * 2, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 42, v)
+
+		v, ok := c.Get(21)
+		assert.True(t, ok)
+		assert.Equal(t, 42, v)
+	})
+}
+
+// collect returns the items yielded by the iterator
+func collect[K comparable, V any](seq func(yield func(K, V) bool)) map[K]V {
+	items := make(map[K]V)
+	seq(func(k K, v V) bool {
+		items[k] = v
+		return true
+	})
+
+	return items
+}
+
+func TestCache_Iteration(t *testing.T) {
+	c := cache.New[int](cache.WithShards(4))
+	c.SetMany(map[string]int{"a": 1, "b": 2, "c": 3})
+	c.SetWithExpiry("expired", 4, time.Millisecond)
+
+	time.Sleep(5 * time.Millisecond)
+
+	t.Run("All should yield the items that have not expired", func(t *testing.T) {
+		assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, collect(c.All()))
+	})
+
+	t.Run("All should stop when yield returns false", func(t *testing.T) {
+		n := 0
+		c.All()(func(string, int) bool {
+			n++
+			return false
+		})
+
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("Range should stop when fn returns false", func(t *testing.T) {
+		n := 0
+		c.Range(func(string, int) bool {
+			n++
+			return n < 2
+		})
+
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("All should allow the cache to be changed while iterating", func(t *testing.T) {
+		c.All()(func(k string, _ int) bool {
+			c.Delete(k)
+			return true
+		})
+
+		assert.Empty(t, collect(c.All()))
+	})
+}
+
+func TestCache_Bulk(t *testing.T) {
+	c := cache.New[int](cache.WithShards(4))
+
+	t.Run("SetMany should set every value", func(t *testing.T) {
+		c.SetMany(map[string]int{"a": 1, "b": 2, "c": 3, "d": 4})
+		assert.Equal(t, 4, c.Len())
+	})
+
+	t.Run("GetMany should only return the keys in the cache", func(t *testing.T) {
+		assert.Equal(t, map[string]int{"a": 1, "c": 3}, c.GetMany("a", "c", "missing"))
+	})
+
+	t.Run("GetMany should not return expired values", func(t *testing.T) {
+		c.SetManyWithExpiry(map[string]int{"e": 5}, time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+
+		assert.Empty(t, c.GetMany("e"))
+	})
+
+	t.Run("DeleteMany should delete every key", func(t *testing.T) {
+		c.DeleteMany("a", "b", "missing")
+
+		assert.ElementsMatch(t, []string{"c", "d"}, c.Keys())
+	})
+}
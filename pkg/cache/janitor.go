@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// janitor periodically removes expired items from the cache
+func (c *Keyed[K, V]) janitor(ctx context.Context, interval time.Duration) {
+	defer close(c.janitorDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Flush()
+		}
+	}
+}
+
+// Close stops the janitor started by WithCleanupInterval and waits for it to finish.
+// The cache can still be used once it has been closed, but expired items are no longer
+// removed in the background. Close can be called more than once.
+func (c *Keyed[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		if c.stopJanitor == nil {
+			return
+		}
+
+		c.stopJanitor()
+		<-c.janitorDone
+	})
+}
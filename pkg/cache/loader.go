@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLoaderPanicked is returned by GetOrLoad when the loader panics
+var ErrLoaderPanicked = errors.New("cache loader panicked")
+
+// minErrorSweep is the number of cached errors there must be before expired errors are swept when storing one
+const minErrorSweep = 64
+
+// KeyedLoaderFn loads the value for a key that is not in a Keyed cache, e.g. from a database
+type KeyedLoaderFn[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// LoaderFn loads the value for a key that is not in the cache, e.g. from a database
+type LoaderFn[T any] func(ctx context.Context, key string) (T, error)
+
+// loadError is an error returned by a loader, remembered until it expires
+type loadError struct {
+	err    error
+	expiry int64
+}
+
+// GetOrLoad returns the value for the given key, calling the loader and caching the value it returns
+// with the default expiry if the key is not in the cache. Concurrent calls for the same key share a
+// single call to the loader, which is not cancelled if the caller that started it gives up waiting.
+// If WithErrorTTL is set, errors returned by the loader are returned for the same key until the TTL
+// has passed without calling the loader again. If WithRefreshAhead is set, items that are about to
+// expire are reloaded in the background while the current value is returned. A value loaded for a key
+// that is set or deleted while the loader runs is returned to the callers, but is not cached.
+func (c *Keyed[K, V]) GetOrLoad(ctx context.Context, key K, loader KeyedLoaderFn[K, V]) (V, error) {
+	i, ok := c.shard(key).get(key)
+	if ok {
+		if c.options.refreshAhead > 0 && i.expiry != 0 &&
+			time.Until(time.Unix(0, i.expiry)) < c.options.refreshAhead {
+			// nobody needs to wait for the refresh, the result is cached when it is done
+			c.loads.do(key, c.loadFn(ctx, key, loader))
+		}
+
+		return i.value, nil
+	}
+
+	var v V
+
+	if err := c.loadError(key); err != nil {
+		return v, err
+	}
+
+	f := c.loads.do(key, c.loadFn(ctx, key, loader))
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return v, ctx.Err()
+	}
+}
+
+// GetOrLoad returns the value for the given key, calling the loader and caching the value it returns
+// if the key is not in the cache, in the same way as Keyed.GetOrLoad
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, loader LoaderFn[T]) (T, error) {
+	return c.Keyed.GetOrLoad(ctx, key, KeyedLoaderFn[string, T](loader))
+}
+
+// loadFn returns the function that calls the loader and caches the result. The loader keeps the
+// values of the context but is not cancelled with it, as its result is shared with other callers.
+// The result is not cached if the key is set or deleted while it is being loaded, as it may be stale.
+func (c *Keyed[K, V]) loadFn(ctx context.Context, key K, loader KeyedLoaderFn[K, V]) func() (V, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	return func() (v V, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v: %v", ErrLoaderPanicked, key, r)
+				c.storeError(key, err)
+			}
+		}()
+
+		v, err = loader(ctx, key)
+		if err != nil {
+			c.storeError(key, err)
+			return v, err
+		}
+
+		c.shard(key).setUnless(key, v, c.options.expiry, func() bool { return c.loads.stale(key) })
+
+		return v, nil
+	}
+}
+
+// flight is a call to a loader that is in progress, done is closed once the value or error is set.
+// It is stale once the key has been written to since the call started.
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+	stale bool
+}
+
+// flights tracks the loads in progress so that concurrent loads of the same key share a single call
+type flights[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flight[V]
+}
+
+// do calls fn in the background unless a call for the key is already in progress, and returns the call
+func (f *flights[K, V]) do(key K, fn func() (V, error)) *flight[V] {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if call, ok := f.calls[key]; ok {
+		return call
+	}
+
+	call := &flight[V]{done: make(chan struct{})}
+	f.calls[key] = call
+
+	go func() {
+		call.value, call.err = fn()
+
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	return call
+}
+
+// invalidate marks the call for the key that is in progress as stale, so that its result is not cached
+func (f *flights[K, V]) invalidate(key K) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if call, ok := f.calls[key]; ok {
+		call.stale = true
+	}
+}
+
+// invalidateAll marks every call that is in progress as stale
+func (f *flights[K, V]) invalidateAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, call := range f.calls {
+		call.stale = true
+	}
+}
+
+// stale returns true if the call for the key that is in progress is stale
+func (f *flights[K, V]) stale(key K) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call, ok := f.calls[key]
+
+	return ok && call.stale
+}
+
+// loadError returns the error from loading the key if it has not expired
+func (c *Keyed[K, V]) loadError(key K) error {
+	if c.options.errorTTL == 0 {
+		return nil
+	}
+
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	e, ok := c.errors[key]
+	if !ok {
+		return nil
+	}
+
+	if e.expiry < time.Now().UnixNano() {
+		delete(c.errors, key)
+		return nil
+	}
+
+	return e.err
+}
+
+func (c *Keyed[K, V]) storeError(key K, err error) {
+	if c.options.errorTTL == 0 {
+		return
+	}
+
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	// the key has been written to since the load started, so the error no longer applies to it
+	if c.loads.stale(key) {
+		return
+	}
+
+	now := time.Now()
+
+	// errors are only removed when their key is read again, so expired errors for keys that are not read
+	// again are swept as the errors grow, keeping the errors to at most twice the errors that have not expired
+	if len(c.errors) >= c.errSweepAt {
+		c.sweepErrors(now.UnixNano())
+		c.errSweepAt = max(2*len(c.errors), minErrorSweep)
+	}
+
+	c.errors[key] = loadError{err: err, expiry: now.Add(c.options.errorTTL).UnixNano()}
+}
+
+// flushErrors removes the expired errors
+func (c *Keyed[K, V]) flushErrors() {
+	if c.options.errorTTL == 0 {
+		return
+	}
+
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	c.sweepErrors(time.Now().UnixNano())
+}
+
+// sweepErrors removes the errors that expired before the time in nanoseconds past epoch, the lock must be held
+func (c *Keyed[K, V]) sweepErrors(now int64) {
+	for k, e := range c.errors {
+		if e.expiry < now {
+			delete(c.errors, k)
+		}
+	}
+}
+
+// written forgets the error from loading the key, and stops a load of the key that is in progress from
+// caching its result, as the key has been set or deleted
+func (c *Keyed[K, V]) written(key K) {
+	c.loads.invalidate(key)
+	c.forgetError(key)
+}
+
+func (c *Keyed[K, V]) forgetError(key K) {
+	if c.options.errorTTL == 0 {
+		return
+	}
+
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	delete(c.errors, key)
+}
+
+func (c *Keyed[K, V]) clearErrors() {
+	if c.options.errorTTL == 0 {
+		return
+	}
+
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+
+	c.errors = make(map[K]loadError)
+}
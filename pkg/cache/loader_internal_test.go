@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_errors(t *testing.T) {
+	ctx := context.Background()
+	errLoad := errors.New("load failed")
+	loader := func(context.Context, string) (int, error) { return 0, errLoad }
+
+	errorCount := func(c *Cache[int]) int {
+		c.errMu.Lock()
+		defer c.errMu.Unlock()
+
+		return len(c.errors)
+	}
+
+	t.Run("Expired errors should be swept as new errors are stored", func(t *testing.T) {
+		c := New[int](WithErrorTTL(time.Millisecond))
+
+		for i := 0; i < 10*minErrorSweep; i++ {
+			_, _ = c.GetOrLoad(ctx, strconv.Itoa(i), loader)
+
+			if i%minErrorSweep == 0 {
+				time.Sleep(2 * time.Millisecond)
+			}
+		}
+
+		assert.LessOrEqual(t, errorCount(c), 2*minErrorSweep)
+	})
+
+	t.Run("Flush should remove expired errors", func(t *testing.T) {
+		c := New[int](WithErrorTTL(time.Millisecond))
+
+		for i := 0; i < 10; i++ {
+			_, _ = c.GetOrLoad(ctx, strconv.Itoa(i), loader)
+		}
+
+		time.Sleep(2 * time.Millisecond)
+		c.Flush()
+
+		assert.Equal(t, 0, errorCount(c))
+	})
+}
@@ -0,0 +1,247 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+var errLoad = errors.New("load failed")
+
+func TestCache_GetOrLoad(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("GetOrLoad should load and cache a missing value", func(t *testing.T) {
+		c := cache.New[string]()
+
+		var calls atomic.Int32
+		loader := func(_ context.Context, key string) (string, error) {
+			calls.Add(1)
+			return key + "-value", nil
+		}
+
+		for i := 0; i < 3; i++ {
+			v, err := c.GetOrLoad(ctx, "foo", loader)
+			require.NoError(t, err)
+			assert.Equal(t, "foo-value", v)
+		}
+
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("GetOrLoad should share a load between concurrent callers", func(t *testing.T) {
+		c := cache.New[int]()
+
+		var calls atomic.Int32
+		release := make(chan struct{})
+		loader := func(context.Context, string) (int, error) {
+			calls.Add(1)
+			<-release
+			return 42, nil
+		}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				v, err := c.GetOrLoad(ctx, "foo", loader)
+				assert.NoError(t, err)
+				assert.Equal(t, 42, v)
+			}()
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("GetOrLoad should not cache errors by default", func(t *testing.T) {
+		c := cache.New[int]()
+
+		var calls atomic.Int32
+		loader := func(context.Context, string) (int, error) {
+			calls.Add(1)
+			return 0, errLoad
+		}
+
+		_, err := c.GetOrLoad(ctx, "foo", loader)
+		assert.ErrorIs(t, err, errLoad)
+		_, err = c.GetOrLoad(ctx, "foo", loader)
+		assert.ErrorIs(t, err, errLoad)
+
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("GetOrLoad should cache errors for the error TTL", func(t *testing.T) {
+		c := cache.New[int](cache.WithErrorTTL(20 * time.Millisecond))
+
+		var calls atomic.Int32
+		loader := func(context.Context, string) (int, error) {
+			calls.Add(1)
+			return 0, errLoad
+		}
+
+		_, err := c.GetOrLoad(ctx, "foo", loader)
+		assert.ErrorIs(t, err, errLoad)
+		_, err = c.GetOrLoad(ctx, "foo", loader)
+		assert.ErrorIs(t, err, errLoad)
+		assert.Equal(t, int32(1), calls.Load())
+
+		time.Sleep(25 * time.Millisecond)
+
+		_, err = c.GetOrLoad(ctx, "foo", loader)
+		assert.ErrorIs(t, err, errLoad)
+		assert.Equal(t, int32(2), calls.Load())
+
+		c.Set("foo", 1)
+		v, err := c.GetOrLoad(ctx, "foo", loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("GetOrLoad should refresh items that are about to expire", func(t *testing.T) {
+		c := cache.New[int](cache.WithExpiry(100*time.Millisecond), cache.WithRefreshAhead(80*time.Millisecond))
+
+		var calls atomic.Int32
+		loader := func(context.Context, string) (int, error) {
+			return int(calls.Add(1)), nil
+		}
+
+		v, err := c.GetOrLoad(ctx, "foo", loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, v)
+
+		time.Sleep(30 * time.Millisecond)
+
+		v, err = c.GetOrLoad(ctx, "foo", loader)
+		require.NoError(t, err)
+		assert.Equal(t, 1, v)
+
+		assert.Eventually(t, func() bool {
+			v, _ := c.Get("foo")
+			return v == 2
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("GetOrLoad should stop waiting when the context is done", func(t *testing.T) {
+		c := cache.New[int]()
+
+		release := make(chan struct{})
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := c.GetOrLoad(ctx, "foo", func(context.Context, string) (int, error) {
+			<-release
+			return 1, nil
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("GetOrLoad should not overwrite a value set while it was loading", func(t *testing.T) {
+		c := cache.New[int]()
+
+		loading, release := make(chan struct{}), make(chan struct{})
+		loader := func(context.Context, string) (int, error) {
+			close(loading)
+			<-release
+			return 1, nil
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			v, err := c.GetOrLoad(ctx, "foo", loader)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, v)
+		}()
+
+		<-loading
+		c.Set("foo", 2)
+		close(release)
+		<-done
+
+		v, ok := c.Get("foo")
+		assert.True(t, ok)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("GetOrLoad should not restore a value deleted while it was loading", func(t *testing.T) {
+		c := cache.New[int]()
+
+		loading, release := make(chan struct{}), make(chan struct{})
+		loader := func(context.Context, string) (int, error) {
+			close(loading)
+			<-release
+			return 1, nil
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			_, _ = c.GetOrLoad(ctx, "foo", loader)
+		}()
+
+		<-loading
+		c.Delete("foo")
+		close(release)
+		<-done
+
+		_, ok := c.Get("foo")
+		assert.False(t, ok)
+	})
+
+	t.Run("GetOrLoad should not cache an error for a key set while it was loading", func(t *testing.T) {
+		c := cache.New[int](cache.WithErrorTTL(time.Minute))
+
+		loading, release := make(chan struct{}), make(chan struct{})
+		loader := func(context.Context, string) (int, error) {
+			close(loading)
+			<-release
+			return 0, errLoad
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			_, _ = c.GetOrLoad(ctx, "foo", loader)
+		}()
+
+		<-loading
+		c.Set("foo", 2)
+		c.Delete("foo")
+		close(release)
+		<-done
+
+		v, err := c.GetOrLoad(ctx, "foo", func(context.Context, string) (int, error) { return 3, nil })
+		require.NoError(t, err)
+		assert.Equal(t, 3, v)
+	})
+
+	t.Run("GetOrLoad should return an error when the loader panics", func(t *testing.T) {
+		c := cache.New[int]()
+
+		_, err := c.GetOrLoad(ctx, "foo", func(context.Context, string) (int, error) {
+			panic("boom")
+		})
+		assert.ErrorIs(t, err, cache.ErrLoaderPanicked)
+	})
+}
@@ -1,10 +1,41 @@
 package cache
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOptionType is the error a cache panics with when it is created with an option for keys or values of
+// other types, e.g. WithSizer with a function for int keys passed to a cache with string keys
+var ErrOptionType = errors.New("cache option is for other key or value types")
 
 type options struct {
 	// expiry is the default expiry for items in the cache
 	expiry time.Duration
+	// maxEntries is the maximum number of items in the cache, 0 is unlimited
+	maxEntries int
+	// maxCost is the maximum total cost of the items in the cache, 0 is unlimited
+	maxCost int64
+	// sizer is the func(K, V) int64 that returns the cost of an item, every item costs 1 if it is not set
+	sizer any
+	// policy decides which items are evicted when the cache is full
+	policy EvictionPolicy
+	// onEvict is the KeyedEvictFn[K, V] called with every item that is removed from the cache
+	onEvict any
+	// onExpire is the KeyedEvictFn[K, V] called with every item that is removed from the cache because it expired
+	onExpire any
+	// cleanupInterval is how often expired items are removed in the background, 0 disables the janitor
+	cleanupInterval time.Duration
+	// ctx stops the janitor when it is done
+	ctx context.Context //nolint:containedctx
+	// shards is the number of partitions the items are split between
+	shards int
+	// errorTTL is how long GetOrLoad remembers that loading a key failed, 0 does not remember errors
+	errorTTL time.Duration
+	// refreshAhead is how long before an item expires that GetOrLoad reloads it in the background
+	refreshAhead time.Duration
 }
 
 type Option func(*options)
@@ -15,8 +46,144 @@ func WithExpiry(expiry time.Duration) Option {
 	}
 }
 
+// WithMaxEntries limits the number of items in the cache, items are evicted using the
+// eviction policy to make room for new ones
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxCost limits the total cost of the items in the cache, items are evicted using the
+// eviction policy to make room for new ones. The cost of each item is calculated by the sizer
+// set with WithSizer, or is 1 if there is no sizer. Items that cost more than the maximum are
+// not added to the cache.
+func WithMaxCost(cost int64) Option {
+	return func(o *options) {
+		o.maxCost = cost
+	}
+}
+
+// WithSizer sets the function used to calculate the cost of an item for WithMaxCost,
+// e.g. the size of the value in bytes. K and V must be the types of the keys and values in the cache,
+// the cache panics with ErrOptionType when it is created if they are not.
+func WithSizer[K comparable, V any](fn func(key K, value V) int64) Option {
+	return func(o *options) {
+		o.sizer = fn
+	}
+}
+
+// WithPolicy sets the policy used to evict items when the cache has reached its capacity,
+// the default is PolicyLRU
+func WithPolicy(p EvictionPolicy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// WithOnEvict sets a callback that is called with every item that is removed from the cache, whether it
+// expired, was evicted, deleted, replaced by setting the key again or cleared, e.g. to release resources
+// held by the value.
+// Callbacks are called after the cache has been unlocked. K and V must be the types of the keys and values in the cache,
+// the cache panics with ErrOptionType when it is created if they are not.
+func WithOnEvict[K comparable, V any](fn func(key K, value V, reason EvictionReason)) Option {
+	return func(o *options) {
+		o.onEvict = KeyedEvictFn[K, V](fn)
+	}
+}
+
+// WithOnExpire sets a callback that is called with every item that is removed from the cache because
+// it expired. Callbacks are called after the cache has been unlocked. K and V must be the types of the keys
+// and values in the cache, the cache panics with ErrOptionType when it is created if they are not.
+func WithOnExpire[K comparable, V any](fn func(key K, value V, reason EvictionReason)) Option {
+	return func(o *options) {
+		o.onExpire = KeyedEvictFn[K, V](fn)
+	}
+}
+
+// WithCleanupInterval starts a janitor that removes expired items, and expired errors cached by GetOrLoad,
+// from the cache at the interval.
+// The janitor runs until the cache is closed, or the context set with WithContext is done.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.cleanupInterval = interval
+	}
+}
+
+// WithContext stops the janitor started by WithCleanupInterval when the context is done
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithShards partitions the cache into the given number of shards by key, each with its own lock, so that
+// concurrent operations on different keys do not contend with each other. The capacity set with
+// WithMaxEntries or WithMaxCost is split evenly between the shards, so items may be evicted from a
+// shard before the cache as a whole is full. A cache never has more shards than its capacity.
+// The default is a single shard.
+func WithShards(n int) Option {
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// WithErrorTTL caches the errors returned by the loader passed to GetOrLoad for the duration, so that
+// a key that failed to load is not loaded again until the duration has passed. Expired errors are removed
+// by Flush, and as more errors are cached.
+func WithErrorTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.errorTTL = ttl
+	}
+}
+
+// WithRefreshAhead reloads items in the background when GetOrLoad is called within the duration of
+// the item expiring. The current value is returned while the item is being reloaded.
+func WithRefreshAhead(d time.Duration) Option {
+	return func(o *options) {
+		o.refreshAhead = d
+	}
+}
+
 func defaultOptions() options {
 	return options{
 		expiry: NoExpiry,
+		policy: PolicyLRU,
+		ctx:    context.Background(),
+		shards: 1,
+	}
+}
+
+// callbacks are the functions set with the typed options, with the types of the keys and values of the cache
+type callbacks[K comparable, V any] struct {
+	sizer    func(K, V) int64
+	onEvict  KeyedEvictFn[K, V]
+	onExpire KeyedEvictFn[K, V]
+}
+
+// newCallbacks returns the functions set with the typed options, panicking with ErrOptionType if any of
+// them is for other key or value types, so the mistake is found when the cache is created rather than
+// by the callbacks being given zero values
+func newCallbacks[K comparable, V any](o *options) callbacks[K, V] {
+	return callbacks[K, V]{
+		sizer:    typedOption[func(K, V) int64]("WithSizer", o.sizer),
+		onEvict:  typedOption[KeyedEvictFn[K, V]]("WithOnEvict", o.onEvict),
+		onExpire: typedOption[KeyedEvictFn[K, V]]("WithOnExpire", o.onExpire),
 	}
 }
+
+// typedOption returns the function set with the named option as an F, the function is nil if the option was not set
+func typedOption[F any](name string, fn any) F {
+	var typed F
+
+	if fn == nil {
+		return typed
+	}
+
+	typed, ok := fn.(F)
+	if !ok {
+		panic(fmt.Errorf("%w: %s was given a %T but the cache needs a %T", ErrOptionType, name, fn, typed))
+	}
+
+	return typed
+}
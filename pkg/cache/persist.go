@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	iofs "gitlab.com/gobl/gobl/pkg/io/fs"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+const (
+	persistDirMode  = 0o755
+	persistFileMode = 0o600
+)
+
+// persisted is an item written to a cache dump
+type persisted[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+	// Expiry is when the item expires as nanoseconds past epoch, 0 if it does not expire
+	Expiry int64 `json:"expiry"`
+}
+
+// Save writes the items in the cache that have not expired, with their expiry, to the writer
+// using the codec. The codec must be able to serialise a slice, e.g. JSONCodec or GobCodec.
+func (c *Keyed[K, V]) Save(w io.Writer, codec Codec) error {
+	items := make([]persisted[K, V], 0, c.Len())
+	for _, s := range c.shards {
+		items = s.snapshot(items)
+	}
+
+	data, err := codec.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("encoding cache: %w", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the items written by Save from the reader and adds them to the cache with the
+// time they had left to live. Items that have expired since they were saved are skipped.
+// It returns the number of items added.
+func (c *Keyed[K, V]) Load(r io.Reader, codec Codec) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("reading cache: %w", err)
+	}
+
+	var items []persisted[K, V]
+	if err := codec.Unmarshal(data, &items); err != nil {
+		return 0, fmt.Errorf("decoding cache: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+	loaded := 0
+
+	for _, i := range items {
+		if i.Expiry == 0 {
+			c.SetWithExpiry(i.Key, i.Value, NoExpiry)
+			loaded++
+
+			continue
+		}
+
+		if i.Expiry <= now {
+			continue
+		}
+
+		c.SetWithExpiry(i.Key, i.Value, time.Duration(i.Expiry-now))
+		loaded++
+	}
+
+	return loaded, nil
+}
+
+// SaveFile writes the cache to the file with Save. The cache is written to a temporary file that is
+// synced to disk and renamed over the file, so a crash while saving leaves the previous file intact.
+func (c *Keyed[K, V]) SaveFile(path string, codec Codec) error {
+	if err := os.MkdirAll(filepath.Dir(path), persistDirMode); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	if err := iofs.WriteAtomically(path, persistFileMode, func(w io.Writer) error {
+		return c.Save(w, codec)
+	}); err != nil {
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFile reads the cache from the file written by SaveFile with Load.
+// A missing file is not an error, as there is nothing to load on the first start.
+func (c *Keyed[K, V]) LoadFile(path string, codec Codec) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("opening cache file: %w", err)
+	}
+	defer f.Close()
+
+	return c.Load(f, codec)
+}
+
+// FilePersister is a cache that can be saved to and loaded from a file, such as Cache or Keyed
+type FilePersister interface {
+	SaveFile(path string, codec Codec) error
+	LoadFile(path string, codec Codec) (int, error)
+}
+
+// Persistence returns a service initialisation function that warms the cache from the file, and a
+// cleanup function that saves the cache to the file, so that the cache survives service restarts.
+//
+//nolint:gocritic
+func Persistence(c FilePersister, path string, codec Codec) (service.InitFunc, service.CleanupFunc) {
+	initFn := func(_ context.Context, _ service.State) error {
+		if _, err := c.LoadFile(path, codec); err != nil {
+			return fmt.Errorf("warming cache from %s: %w", path, err)
+		}
+
+		return nil
+	}
+
+	cleanupFn := func(_ service.State) error {
+		if err := c.SaveFile(path, codec); err != nil {
+			return fmt.Errorf("saving cache to %s: %w", path, err)
+		}
+
+		return nil
+	}
+
+	return initFn, cleanupFn
+}
+
+// snapshot appends the items in the shard that have not expired
+func (s *shard[K, V]) snapshot(items []persisted[K, V]) []persisted[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UnixNano()
+
+	for k, i := range s.data {
+		if i.expired(now) {
+			continue
+		}
+
+		items = append(items, persisted[K, V]{Key: k, Value: i.value, Expiry: i.expiry})
+	}
+
+	return items
+}
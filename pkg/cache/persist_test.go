@@ -0,0 +1,99 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+func TestCache_Persistence(t *testing.T) {
+	c := cache.New[codecValue]()
+	c.Set("forever", codecValue{Foo: "forever", Bar: 1})
+	c.SetWithExpiry("later", codecValue{Foo: "later", Bar: 2}, time.Hour)
+	c.SetWithExpiry("soon", codecValue{Foo: "soon", Bar: 3}, 100*time.Millisecond)
+
+	for name, codec := range map[string]cache.Codec{
+		"JSONCodec": cache.JSONCodec{},
+		"GobCodec":  cache.GobCodec{},
+	} {
+		t.Run("Save and Load should round trip the cache with "+name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, c.Save(&buf, codec))
+
+			restored := cache.New[codecValue]()
+			n, err := restored.Load(&buf, codec)
+			require.NoError(t, err)
+			assert.Equal(t, 3, n)
+
+			v, ok := restored.Get("later")
+			assert.True(t, ok)
+			assert.Equal(t, codecValue{Foo: "later", Bar: 2}, v)
+		})
+	}
+
+	t.Run("Load should skip items that expired since they were saved", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, c.Save(&buf, cache.JSONCodec{}))
+
+		time.Sleep(150 * time.Millisecond)
+
+		restored := cache.New[codecValue]()
+		n, err := restored.Load(&buf, cache.JSONCodec{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.ElementsMatch(t, []string{"forever", "later"}, restored.Keys())
+	})
+
+	t.Run("Persistence should save on cleanup and warm the cache on init", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache", "values.json")
+
+		empty := cache.New[codecValue]()
+		initFn, _ := cache.Persistence(empty, path, cache.JSONCodec{})
+		require.NoError(t, initFn(context.Background(), nil))
+		assert.Equal(t, 0, empty.Len())
+
+		_, cleanupFn := cache.Persistence(c, path, cache.JSONCodec{})
+		require.NoError(t, cleanupFn(nil))
+
+		warm := cache.New[codecValue]()
+		initFn, _ = cache.Persistence(warm, path, cache.JSONCodec{})
+		require.NoError(t, initFn(context.Background(), nil))
+		assert.ElementsMatch(t, []string{"forever", "later"}, warm.Keys())
+	})
+
+	t.Run("SaveFile should leave a complete file when saves run concurrently", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "values.json")
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, c.SaveFile(path, cache.JSONCodec{}))
+			}()
+		}
+
+		wg.Wait()
+
+		restored := cache.New[codecValue]()
+		_, err := restored.LoadFile(path, cache.JSONCodec{})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"forever", "later"}, restored.Keys())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// EvictionPolicy decides which items are removed from a cache that has reached its capacity
+type EvictionPolicy int
+
+const (
+	// PolicyLRU evicts the least recently used item
+	PolicyLRU EvictionPolicy = iota
+	// PolicyLFU evicts the least frequently used item, the least recently used of those if there is a tie
+	PolicyLFU
+	// PolicyTinyLFU evicts the least recently used item, but only admits a new item if it has been
+	// requested more often than the item it would replace. The frequencies are estimated with a
+	// count-min sketch that is periodically aged, so one-off keys cannot flush out popular ones.
+	PolicyTinyLFU
+)
+
+// String returns the name of the eviction policy
+func (p EvictionPolicy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyLFU:
+		return "lfu"
+	case PolicyTinyLFU:
+		return "tinylfu"
+	default:
+		return "unknown"
+	}
+}
+
+// policy tracks the keys in a cache to choose which one to evict when the cache is full
+type policy[K comparable] interface {
+	// record is called whenever the key is requested, whether or not it is in the cache
+	record(key K)
+	// add starts tracking a key that has been added to the cache
+	add(key K)
+	// access marks a key in the cache as used
+	access(key K)
+	// remove stops tracking a key that has been removed from the cache
+	remove(key K)
+	// victim returns the key that should be evicted next
+	victim() (K, bool)
+	// admit returns true if the candidate key should replace the victim
+	admit(candidate, victim K) bool
+	// clear stops tracking all keys
+	clear()
+}
+
+func newPolicy[K comparable](p EvictionPolicy, capacity int) policy[K] {
+	switch p {
+	case PolicyLFU:
+		return newLFU[K]()
+	case PolicyTinyLFU:
+		return newTinyLFU[K](capacity)
+	default:
+		return newLRU[K]()
+	}
+}
+
+// lru orders keys by how recently they were used, the front of the list is the most recent
+type lru[K comparable] struct {
+	order *list.List
+	keys  map[K]*list.Element
+}
+
+func newLRU[K comparable]() *lru[K] {
+	return &lru[K]{
+		order: list.New(),
+		keys:  make(map[K]*list.Element),
+	}
+}
+
+func (p *lru[K]) record(K) {}
+
+func (p *lru[K]) add(key K) {
+	p.keys[key] = p.order.PushFront(key)
+}
+
+func (p *lru[K]) access(key K) {
+	if e, ok := p.keys[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lru[K]) remove(key K) {
+	if e, ok := p.keys[key]; ok {
+		p.order.Remove(e)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lru[K]) victim() (K, bool) {
+	e := p.order.Back()
+	if e == nil {
+		var k K
+		return k, false
+	}
+
+	return e.Value.(K), true //nolint:forcetypeassert
+}
+
+func (p *lru[K]) admit(K, K) bool {
+	return true
+}
+
+func (p *lru[K]) clear() {
+	p.order.Init()
+	p.keys = make(map[K]*list.Element)
+}
+
+// lfu orders keys by how often they have been used, using a min heap
+type lfu[K comparable] struct {
+	entries lfuHeap[K]
+	keys    map[K]*lfuEntry[K]
+	tick    uint64
+}
+
+type lfuEntry[K comparable] struct {
+	key   K
+	count uint64
+	// used is when the key was last used so ties are broken by recency
+	used  uint64
+	index int
+}
+
+func newLFU[K comparable]() *lfu[K] {
+	return &lfu[K]{
+		keys: make(map[K]*lfuEntry[K]),
+	}
+}
+
+func (p *lfu[K]) record(K) {}
+
+func (p *lfu[K]) add(key K) {
+	p.tick++
+	e := &lfuEntry[K]{key: key, count: 1, used: p.tick}
+	p.keys[key] = e
+	heap.Push(&p.entries, e)
+}
+
+func (p *lfu[K]) access(key K) {
+	e, ok := p.keys[key]
+	if !ok {
+		return
+	}
+
+	p.tick++
+	e.count++
+	e.used = p.tick
+	heap.Fix(&p.entries, e.index)
+}
+
+func (p *lfu[K]) remove(key K) {
+	if e, ok := p.keys[key]; ok {
+		heap.Remove(&p.entries, e.index)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lfu[K]) victim() (K, bool) {
+	if len(p.entries) == 0 {
+		var k K
+		return k, false
+	}
+
+	return p.entries[0].key, true
+}
+
+func (p *lfu[K]) admit(K, K) bool {
+	return true
+}
+
+func (p *lfu[K]) clear() {
+	p.entries = nil
+	p.keys = make(map[K]*lfuEntry[K])
+}
+
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].count == h[j].count {
+		return h[i].used < h[j].used
+	}
+
+	return h[i].count < h[j].count
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x any) {
+	e := x.(*lfuEntry[K]) //nolint:forcetypeassert
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return e
+}
+
+// tinyLFU evicts keys in LRU order, but only admits new keys that are estimated to be
+// requested more often than the victim
+type tinyLFU[K comparable] struct {
+	*lru[K]
+	sketch *sketch
+}
+
+func newTinyLFU[K comparable](capacity int) *tinyLFU[K] {
+	return &tinyLFU[K]{
+		lru:    newLRU[K](),
+		sketch: newSketch(capacity),
+	}
+}
+
+func (p *tinyLFU[K]) record(key K) {
+	p.sketch.increment(hashKey(p.sketch.seed, key))
+}
+
+func (p *tinyLFU[K]) admit(candidate, victim K) bool {
+	return p.sketch.estimate(hashKey(p.sketch.seed, candidate)) >
+		p.sketch.estimate(hashKey(p.sketch.seed, victim))
+}
+
+func (p *tinyLFU[K]) clear() {
+	p.lru.clear()
+	p.sketch.reset()
+}
@@ -0,0 +1,130 @@
+package cache_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+func sortedKeys[T any](c *cache.Cache[T]) []string {
+	keys := c.Keys()
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestCache_LRU(t *testing.T) {
+	c := cache.New[int](cache.WithMaxEntries(2))
+
+	t.Run("Set should evict the least recently used item when the cache is full", func(t *testing.T) {
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Get("a")
+		c.Set("c", 3)
+
+		assert.Equal(t, []string{"a", "c"}, sortedKeys(c))
+	})
+
+	t.Run("Set should not evict anything when updating an item", func(t *testing.T) {
+		c.Set("a", 10)
+
+		assert.Equal(t, []string{"a", "c"}, sortedKeys(c))
+		v, _ := c.Get("a")
+		assert.Equal(t, 10, v)
+	})
+
+	t.Run("Delete should make room in the cache", func(t *testing.T) {
+		c.Delete("a")
+		c.Set("d", 4)
+
+		assert.Equal(t, []string{"c", "d"}, sortedKeys(c))
+	})
+}
+
+func TestCache_LFU(t *testing.T) {
+	c := cache.New[int](cache.WithMaxEntries(2), cache.WithPolicy(cache.PolicyLFU))
+
+	t.Run("Set should evict the least frequently used item when the cache is full", func(t *testing.T) {
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Get("a")
+		c.Get("a")
+		c.Get("b")
+		c.Set("c", 3)
+
+		assert.Equal(t, []string{"a", "c"}, sortedKeys(c))
+	})
+
+	t.Run("Set should evict the least recently used item when items are used equally", func(t *testing.T) {
+		c.Get("c")
+		c.Get("c")
+		c.Set("d", 4)
+
+		assert.Equal(t, []string{"c", "d"}, sortedKeys(c))
+	})
+}
+
+func TestCache_TinyLFU(t *testing.T) {
+	c := cache.New[int](cache.WithMaxEntries(2), cache.WithPolicy(cache.PolicyTinyLFU))
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	for i := 0; i < 5; i++ {
+		c.Get("a")
+		c.Get("b")
+	}
+
+	t.Run("Set should not admit an item that is requested less than the victim", func(t *testing.T) {
+		c.Set("c", 3)
+
+		assert.Equal(t, []string{"a", "b"}, sortedKeys(c))
+	})
+
+	t.Run("Set should admit an item that is requested more than the victim", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			c.Get("c")
+		}
+		c.Get("b")
+		c.Set("c", 3)
+
+		assert.Equal(t, []string{"b", "c"}, sortedKeys(c))
+	})
+}
+
+func TestCache_MaxCost(t *testing.T) {
+	c := cache.New[string](cache.WithMaxCost(10), cache.WithSizer(func(_ string, v string) int64 {
+		return int64(len(v))
+	}))
+
+	t.Run("Set should evict items until the new item fits", func(t *testing.T) {
+		c.Set("a", "12345")
+		c.Set("b", "12345")
+		c.Set("c", "123")
+
+		assert.Equal(t, []string{"b", "c"}, sortedKeys(c))
+		assert.Equal(t, int64(8), c.Cost())
+	})
+
+	t.Run("Set should not add an item that costs more than the maximum", func(t *testing.T) {
+		c.Set("d", "12345678901")
+
+		assert.Equal(t, []string{"b", "c"}, sortedKeys(c))
+	})
+
+	t.Run("Set should evict other items when an item grows", func(t *testing.T) {
+		c.Set("c", "12345678")
+
+		assert.Equal(t, []string{"c"}, sortedKeys(c))
+		assert.Equal(t, int64(8), c.Cost())
+	})
+
+	t.Run("Clear should reset the cost", func(t *testing.T) {
+		c.Clear()
+
+		assert.Equal(t, int64(0), c.Cost())
+	})
+}
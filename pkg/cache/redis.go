@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	iostrings "gitlab.com/gobl/gobl/pkg/io/strings"
+	"gitlab.com/gobl/gobl/pkg/logger"
+)
+
+const (
+	// DefaultRedisPrefix is the default prefix of the keys stored in Redis
+	DefaultRedisPrefix = "cache"
+	// DefaultRedisTimeout is the default time allowed for each Redis operation
+	DefaultRedisTimeout = 5 * time.Second
+	// redisScanCount is the number of keys requested from Redis at a time when scanning
+	redisScanCount = 100
+)
+
+// RedisErrorFn is called when a Redis operation fails, with the name of the operation and the key
+type RedisErrorFn func(op, key string, err error)
+
+type redisOptions struct {
+	// prefix is prepended to every key so several caches can share a Redis database
+	prefix string
+	// codec serialises the values
+	codec Codec
+	// expiry is the default expiry for items in the cache
+	expiry time.Duration
+	// timeout is the time allowed for each Redis operation
+	timeout time.Duration
+	// onError is called when a Redis operation fails
+	onError RedisErrorFn
+}
+
+// RedisOption configures optional behaviour of a Redis cache
+type RedisOption func(*redisOptions)
+
+// WithRedisPrefix sets the prefix of the keys stored in Redis. Clear, Len and Keys only
+// operate on the keys with the prefix.
+func WithRedisPrefix(prefix string) RedisOption {
+	return func(o *redisOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithRedisCodec sets the codec used to serialise the values, the default is JSONCodec
+func WithRedisCodec(c Codec) RedisOption {
+	return func(o *redisOptions) {
+		o.codec = c
+	}
+}
+
+// WithRedisExpiry sets the default expiry for items in the cache
+func WithRedisExpiry(expiry time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.expiry = expiry
+	}
+}
+
+// WithRedisTimeout sets the time allowed for each Redis operation
+func WithRedisTimeout(timeout time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRedisErrorHook sets the function called when a Redis operation fails. By default the errors are logged.
+func WithRedisErrorHook(fn RedisErrorFn) RedisOption {
+	return func(o *redisOptions) {
+		o.onError = fn
+	}
+}
+
+func defaultRedisOptions() redisOptions {
+	return redisOptions{
+		prefix:  DefaultRedisPrefix,
+		codec:   JSONCodec{},
+		expiry:  NoExpiry,
+		timeout: DefaultRedisTimeout,
+	}
+}
+
+// Redis is a cache stored in Redis that can be shared between replicas. It implements Store so it can
+// be used in place of the in-process Cache. As the Store methods cannot return errors, a failed
+// operation is reported to the error hook and treated as a miss.
+type Redis[T any] struct {
+	client  redis.UniversalClient
+	options redisOptions
+}
+
+// NewRedis returns a cache of the given type stored in Redis using the client
+func NewRedis[T any](client redis.UniversalClient, opts ...RedisOption) *Redis[T] {
+	def := defaultRedisOptions()
+	for _, o := range opts {
+		o(&def)
+	}
+
+	return &Redis[T]{
+		client:  client,
+		options: def,
+	}
+}
+
+// Get returns the value for the given key if it exists and has not expired
+func (r *Redis[T]) Get(key string) (T, bool) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	var v T
+
+	data, err := r.client.Get(ctx, r.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return v, false
+	}
+
+	if err != nil {
+		r.report("get", key, err)
+		return v, false
+	}
+
+	if err := r.options.codec.Unmarshal(data, &v); err != nil {
+		r.report("decode", key, err)
+		return v, false
+	}
+
+	return v, true
+}
+
+// Set sets the value for the given key with the default expiry
+func (r *Redis[T]) Set(key string, value T) {
+	r.SetWithExpiry(key, value, r.options.expiry)
+}
+
+// SetWithExpiry sets the value for the given key with an expiry
+func (r *Redis[T]) SetWithExpiry(key string, value T, expiry time.Duration) {
+	data, err := r.options.codec.Marshal(value)
+	if err != nil {
+		r.report("encode", key, err)
+		return
+	}
+
+	ctx, cancel := r.context()
+	defer cancel()
+
+	if err := r.client.Set(ctx, r.key(key), data, expiry).Err(); err != nil {
+		r.report("set", key, err)
+	}
+}
+
+// Delete deletes the value for the given key
+func (r *Redis[T]) Delete(key string) {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	if err := r.client.Del(ctx, r.key(key)).Err(); err != nil {
+		r.report("delete", key, err)
+	}
+}
+
+// Clear removes every item with the cache's prefix
+func (r *Redis[T]) Clear() {
+	keys := r.scan("clear")
+	if len(keys) == 0 {
+		return
+	}
+
+	ctx, cancel := r.context()
+	defer cancel()
+
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		r.report("clear", "", err)
+	}
+}
+
+// Len returns the number of items with the cache's prefix
+func (r *Redis[T]) Len() int {
+	return len(r.scan("len"))
+}
+
+// Keys returns the keys of the items with the cache's prefix, without the prefix
+func (r *Redis[T]) Keys() []string {
+	keys := r.scan("keys")
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, r.options.prefix+":")
+	}
+
+	return keys
+}
+
+// scan returns the Redis keys with the cache's prefix, the prefix is escaped so that it only matches itself
+func (r *Redis[T]) scan(op string) []string {
+	ctx, cancel := r.context()
+	defer cancel()
+
+	keys := make([]string, 0)
+
+	it := r.client.Scan(ctx, 0, iostrings.EscapeGlob(r.key(""))+"*", redisScanCount).Iterator()
+	for it.Next(ctx) {
+		keys = append(keys, it.Val())
+	}
+
+	if err := it.Err(); err != nil {
+		r.report(op, "", err)
+	}
+
+	return keys
+}
+
+func (r *Redis[T]) key(key string) string {
+	return r.options.prefix + ":" + key
+}
+
+func (r *Redis[T]) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), r.options.timeout)
+}
+
+func (r *Redis[T]) report(op, key string, err error) {
+	if r.options.onError != nil {
+		r.options.onError(op, key, err)
+		return
+	}
+
+	logger.Logger().Error("Redis cache operation failed",
+		zap.String("op", op),
+		zap.String("key", key),
+		zap.Error(err),
+	)
+}
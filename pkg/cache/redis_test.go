@@ -0,0 +1,98 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+	gobltesting "gitlab.com/gobl/gobl/pkg/testing"
+)
+
+func setupRedis(t *testing.T) gobltesting.RedisScaffold {
+	t.Helper()
+
+	if os.Getenv("GITLAB_CI") != "" {
+		t.Skip("skipping Redis cache tests on GitLab CI")
+	}
+
+	scaffold, err := gobltesting.SetupRedis(t, 10)
+	if err != nil {
+		t.Skipf("redis is not available: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := scaffold.Teardown(t); err != nil {
+			t.Logf("could not teardown Redis: %v", err)
+		}
+	})
+
+	return scaffold
+}
+
+func TestRedis(t *testing.T) {
+	scaffold := setupRedis(t)
+
+	var errs []error
+	c := cache.NewRedis[codecValue](scaffold.Rdb,
+		cache.WithRedisPrefix("test"),
+		cache.WithRedisErrorHook(func(_, _ string, err error) { errs = append(errs, err) }),
+	)
+
+	bar := codecValue{Foo: "bar", Bar: 100}
+
+	t.Run("Set should set a value in the cache", func(t *testing.T) {
+		c.Set("foo", bar)
+		v, ok := c.Get("foo")
+		assert.True(t, ok)
+		assert.Equal(t, bar, v)
+	})
+
+	t.Run("SetWithExpiry should set a value in the cache with an expiry", func(t *testing.T) {
+		c.SetWithExpiry("baz", bar, 100*time.Millisecond)
+		_, ok := c.Get("baz")
+		assert.True(t, ok)
+		time.Sleep(200 * time.Millisecond)
+		_, ok = c.Get("baz")
+		assert.False(t, ok)
+	})
+
+	t.Run("Keys and Len should only include keys with the prefix", func(t *testing.T) {
+		require.NoError(t, scaffold.Rdb.Set(context.Background(), "other", "value", 0).Err())
+		c.Set("qux", bar)
+
+		keys := c.Keys()
+		sort.Strings(keys)
+		assert.Equal(t, []string{"foo", "qux"}, keys)
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("Delete should remove a value from the cache", func(t *testing.T) {
+		c.Delete("foo")
+		_, ok := c.Get("foo")
+		assert.False(t, ok)
+	})
+
+	t.Run("Clear should remove all items from the cache", func(t *testing.T) {
+		c.Clear()
+		assert.Equal(t, 0, c.Len())
+		assert.NoError(t, scaffold.Rdb.Get(context.Background(), "other").Err())
+	})
+
+	t.Run("Clear should only remove the keys of a prefix with glob characters", func(t *testing.T) {
+		glob := cache.NewRedis[codecValue](scaffold.Rdb, cache.WithRedisPrefix("te*"))
+		glob.Set("foo", bar)
+		c.Set("foo", bar)
+
+		glob.Clear()
+		assert.Equal(t, 0, glob.Len())
+		assert.Equal(t, 1, c.Len())
+	})
+
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,376 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// shard is a partition of the cache with its own lock, items and eviction policy
+type shard[K comparable, V any] struct {
+	mu        sync.RWMutex
+	data      map[K]item[V]
+	options   *options
+	callbacks *callbacks[K, V]
+	// policy tracks the keys when the cache has a capacity, cost is the total cost of the items
+	policy     policy[K]
+	maxEntries int
+	maxCost    int64
+	cost       int64
+	// evictions are the items removed while the lock is held, see unlock
+	evictions []eviction[K, V]
+	counters  counters
+}
+
+type item[V any] struct {
+	value  V
+	expiry int64
+	cost   int64
+}
+
+// expired returns true if the item has an expiry that is before the time in nanoseconds past epoch
+func (i item[V]) expired(now int64) bool {
+	return i.expiry != 0 && i.expiry < now
+}
+
+// newShard creates the shard with the given index out of the number of shards, the capacity of the cache is
+// split between them
+func newShard[K comparable, V any](o *options, cb *callbacks[K, V], index, shards int) *shard[K, V] {
+	s := &shard[K, V]{
+		data:       make(map[K]item[V]),
+		options:    o,
+		callbacks:  cb,
+		maxEntries: divide(o.maxEntries, index, shards),
+		maxCost:    divide(o.maxCost, index, shards),
+	}
+
+	if s.maxEntries > 0 || s.maxCost > 0 {
+		s.policy = newPolicy[K](o.policy, s.maxEntries)
+	}
+
+	return s
+}
+
+// divide returns the share of the capacity of the shard with the given index. The remainder is spread over
+// the first shards so the shares add up to the capacity, see shardCount for why no share is 0.
+func divide[N int | int64](capacity N, index, shards int) N {
+	share := capacity / N(shards)
+	if N(index) < capacity%N(shards) {
+		share++
+	}
+
+	return share
+}
+
+// shardCount returns the number of shards to use, there are never more shards than the capacity of the cache
+// so that every shard of a limited cache has a capacity of at least 1, as a capacity of 0 is unlimited
+func shardCount(o *options) int {
+	n := max(o.shards, 1)
+
+	if o.maxEntries > 0 {
+		n = min(n, o.maxEntries)
+	}
+
+	if o.maxCost > 0 && o.maxCost < int64(n) {
+		n = int(o.maxCost)
+	}
+
+	return n
+}
+
+func (s *shard[K, V]) get(key K) (item[V], bool) {
+	// without an eviction policy reads do not change the shard, so they only need the read lock
+	// unless the item has expired and needs to be removed
+	if s.policy == nil {
+		s.mu.RLock()
+		i, ok := s.data[key]
+		s.mu.RUnlock()
+
+		if !ok {
+			s.counters.misses.Add(1)
+			return i, false
+		}
+
+		if !i.expired(time.Now().UnixNano()) {
+			s.counters.hits.Add(1)
+			return i, true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	return s.lookup(key, time.Now().UnixNano())
+}
+
+// getMany adds the items for the keys that are in the shard and have not expired to the map
+func (s *shard[K, V]) getMany(keys []K, values map[K]V) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	now := time.Now().UnixNano()
+
+	for _, k := range keys {
+		if i, ok := s.lookup(k, now); ok {
+			values[k] = i.value
+		}
+	}
+}
+
+// lookup returns the item for the key, removing it if it has expired, the lock must be held
+func (s *shard[K, V]) lookup(key K, now int64) (item[V], bool) {
+	var v item[V]
+
+	if s.policy != nil {
+		s.policy.record(key)
+	}
+
+	i, ok := s.data[key]
+	if !ok {
+		s.counters.misses.Add(1)
+		return v, false
+	}
+
+	if i.expired(now) {
+		s.counters.misses.Add(1)
+		s.remove(key, ReasonExpired)
+		return v, false
+	}
+
+	if s.policy != nil {
+		s.policy.access(key)
+	}
+
+	s.counters.hits.Add(1)
+
+	return i, true
+}
+
+func (s *shard[K, V]) set(key K, value V, expiry time.Duration) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	s.store(key, value, expiry)
+}
+
+// setMany adds the items with the same expiry to the shard
+// setUnless sets the value for the key unless skip returns true, skip is called with the shard locked so
+// that nothing can write to the key between it and the value being set
+func (s *shard[K, V]) setUnless(key K, value V, expiry time.Duration, skip func() bool) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if skip() {
+		return
+	}
+
+	s.store(key, value, expiry)
+}
+
+func (s *shard[K, V]) setMany(keys []K, values map[K]V, expiry time.Duration) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	for _, k := range keys {
+		s.store(k, values[k], expiry)
+	}
+}
+
+// store adds the item to the shard, evicting items to make room for it, the lock must be held
+func (s *shard[K, V]) store(key K, value V, expiry time.Duration) {
+	i := item[V]{
+		value:  value,
+		expiry: expiry.Nanoseconds(),
+	}
+
+	if expiry != NoExpiry {
+		i.expiry = time.Now().Add(expiry).UnixNano()
+	}
+
+	if existing, ok := s.data[key]; ok {
+		s.evicted(key, existing.value, ReasonReplaced)
+	}
+
+	if s.policy == nil {
+		s.data[key] = i
+		return
+	}
+
+	s.policy.record(key)
+
+	i.cost = s.costOf(key, value)
+
+	if existing, ok := s.data[key]; ok {
+		s.data[key] = i
+		s.cost += i.cost - existing.cost
+		s.policy.access(key)
+
+		if !s.full(0, 0) {
+			return
+		}
+
+		// the item has grown, it is re-added so the other items are evicted to make room for it
+		delete(s.data, key)
+		s.cost -= i.cost
+		s.policy.remove(key)
+
+		if !s.fits(i.cost) {
+			return
+		}
+	} else if !s.admit(key, i.cost) {
+		return
+	}
+
+	s.makeRoom(i.cost)
+
+	s.data[key] = i
+	s.cost += i.cost
+	s.policy.add(key)
+}
+
+func (s *shard[K, V]) delete(key K) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	s.remove(key, ReasonDeleted)
+}
+
+func (s *shard[K, V]) deleteMany(keys []K) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	for _, k := range keys {
+		s.remove(k, ReasonDeleted)
+	}
+}
+
+func (s *shard[K, V]) clear() {
+	s.mu.Lock()
+	defer s.unlock()
+
+	for k, i := range s.data {
+		s.evicted(k, i.value, ReasonCleared)
+	}
+
+	s.data = make(map[K]item[V])
+	s.cost = 0
+
+	if s.policy != nil {
+		s.policy.clear()
+	}
+}
+
+func (s *shard[K, V]) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.data)
+}
+
+func (s *shard[K, V]) totalCost() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.policy == nil {
+		return int64(len(s.data))
+	}
+
+	return s.cost
+}
+
+func (s *shard[K, V]) keys(keys []K) []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+func (s *shard[K, V]) flush() {
+	s.mu.Lock()
+	defer s.unlock()
+
+	now := time.Now().UnixNano()
+
+	for k, i := range s.data {
+		if i.expiry == 0 || now < i.expiry {
+			continue
+		}
+		s.remove(k, ReasonExpired)
+	}
+}
+
+// remove deletes the item and stops tracking it, the lock must be held
+func (s *shard[K, V]) remove(key K, reason EvictionReason) {
+	i, ok := s.data[key]
+	if !ok {
+		return
+	}
+
+	delete(s.data, key)
+	s.evicted(key, i.value, reason)
+
+	switch reason {
+	case ReasonExpired:
+		s.counters.expirations.Add(1)
+	case ReasonCapacity:
+		s.counters.evictions.Add(1)
+	case ReasonDeleted, ReasonReplaced, ReasonCleared:
+	}
+
+	if s.policy != nil {
+		s.cost -= i.cost
+		s.policy.remove(key)
+	}
+}
+
+func (s *shard[K, V]) costOf(key K, value V) int64 {
+	if s.callbacks.sizer == nil {
+		return 1
+	}
+
+	return s.callbacks.sizer(key, value)
+}
+
+// full returns true if adding the number of items with the given cost would exceed the capacity of the shard
+func (s *shard[K, V]) full(items int, cost int64) bool {
+	if s.maxEntries > 0 && len(s.data)+items > s.maxEntries {
+		return true
+	}
+
+	return s.maxCost > 0 && s.cost+cost > s.maxCost
+}
+
+// fits returns true if an item with the given cost fits in the empty shard
+func (s *shard[K, V]) fits(cost int64) bool {
+	return s.maxCost == 0 || cost <= s.maxCost
+}
+
+// admit returns true if a new item with the given cost should be added to the shard, an item is not
+// admitted if it can never fit or the shard is full and the policy prefers the item it would replace
+func (s *shard[K, V]) admit(key K, cost int64) bool {
+	if !s.fits(cost) {
+		return false
+	}
+
+	if !s.full(1, cost) {
+		return true
+	}
+
+	victim, ok := s.policy.victim()
+
+	return ok && s.policy.admit(key, victim)
+}
+
+// makeRoom evicts items until an item with the given cost fits in the shard
+func (s *shard[K, V]) makeRoom(cost int64) {
+	for s.full(1, cost) {
+		victim, ok := s.policy.victim()
+		if !ok {
+			return
+		}
+		s.remove(victim, ReasonCapacity)
+	}
+}
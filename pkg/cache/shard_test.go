@@ -0,0 +1,145 @@
+package cache_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+func TestCache_Shards(t *testing.T) {
+	c := cache.New[int](cache.WithShards(8))
+
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), i)
+	}
+
+	t.Run("Get should return the values from every shard", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			v, ok := c.Get(strconv.Itoa(i))
+			assert.True(t, ok)
+			assert.Equal(t, i, v)
+		}
+	})
+
+	t.Run("Len and Keys should include every shard", func(t *testing.T) {
+		assert.Equal(t, 100, c.Len())
+		assert.Len(t, c.Keys(), 100)
+	})
+
+	t.Run("Delete should remove the value from its shard", func(t *testing.T) {
+		c.Delete("50")
+		_, ok := c.Get("50")
+		assert.False(t, ok)
+		assert.Equal(t, 99, c.Len())
+	})
+
+	t.Run("Clear should clear every shard", func(t *testing.T) {
+		c.Clear()
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("The capacity should be split between the shards", func(t *testing.T) {
+		c := cache.New[int](cache.WithShards(4), cache.WithMaxEntries(40))
+
+		for i := 0; i < 1000; i++ {
+			c.Set(strconv.Itoa(i), i)
+		}
+
+		assert.LessOrEqual(t, c.Len(), 40)
+	})
+
+	t.Run("The capacity of the shards should add up to the capacity of the cache", func(t *testing.T) {
+		caches := map[string]*cache.Cache[int]{
+			"entries":       cache.New[int](cache.WithShards(4), cache.WithMaxEntries(10)),
+			"cost":          cache.New[int](cache.WithShards(4), cache.WithMaxCost(10)),
+			"fewer entries": cache.New[int](cache.WithShards(16), cache.WithMaxEntries(10)),
+			"less cost":     cache.New[int](cache.WithShards(16), cache.WithMaxCost(10)),
+		}
+
+		for name, c := range caches {
+			// enough keys that every shard is filled
+			for i := 0; i < 1000; i++ {
+				c.Set(strconv.Itoa(i), i)
+			}
+
+			assert.Equal(t, 10, c.Len(), name)
+		}
+	})
+
+	t.Run("The cache should be safe to use concurrently", func(t *testing.T) {
+		c := cache.New[int](cache.WithShards(4))
+
+		var wg sync.WaitGroup
+
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+
+			go func(g int) {
+				defer wg.Done()
+
+				for i := 0; i < 100; i++ {
+					key := strconv.Itoa(g*100 + i)
+					c.Set(key, i)
+					c.Get(key)
+				}
+			}(g)
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, 800, c.Len())
+	})
+}
+
+const benchmarkKeys = 1024
+
+func benchmarkCache(b *testing.B, c *cache.Cache[int], writeEvery int) {
+	keys := make([]string, benchmarkKeys)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Set(keys[i], i)
+	}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%benchmarkKeys]
+			if writeEvery > 0 && i%writeEvery == 0 {
+				c.Set(key, i)
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_ParallelGet(b *testing.B) {
+	for _, shards := range []int{1, 16, 64} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			benchmarkCache(b, cache.New[int](cache.WithShards(shards)), 0)
+		})
+	}
+}
+
+func BenchmarkCache_ParallelGetSet(b *testing.B) {
+	for _, shards := range []int{1, 16, 64} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			benchmarkCache(b, cache.New[int](cache.WithShards(shards)), 10)
+		})
+	}
+}
+
+func BenchmarkCache_ParallelGetLRU(b *testing.B) {
+	for _, shards := range []int{1, 16, 64} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			benchmarkCache(b, cache.New[int](cache.WithShards(shards), cache.WithMaxEntries(benchmarkKeys*2)), 0)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"hash/maphash"
+)
+
+const (
+	// sketchDepth is the number of rows in the count-min sketch
+	sketchDepth = 4
+	// sketchMinWidth is the smallest number of counters in each row of the sketch
+	sketchMinWidth = 64
+	// sketchMaxCount is the highest value a counter can reach
+	sketchMaxCount = 15
+	// sketchSampleFactor is the number of increments, as a multiple of the width, after which the counters are halved
+	sketchSampleFactor = 10
+)
+
+// sketch is a count-min sketch that estimates how often keys have been requested. The counters are
+// halved once enough increments have been made so that old popularity fades away.
+type sketch struct {
+	seed      maphash.Seed
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	sample    int
+}
+
+func newSketch(capacity int) *sketch {
+	width := sketchMinWidth
+	for width < capacity {
+		width <<= 1
+	}
+
+	s := &sketch{
+		seed:   maphash.MakeSeed(),
+		mask:   uint64(width - 1),
+		sample: width * sketchSampleFactor,
+	}
+
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+
+	return s
+}
+
+// increment adds one to the counters for the hash of a key, hashed with the seed of the sketch
+func (s *sketch) increment(h uint64) {
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sample {
+		s.age()
+	}
+}
+
+// estimate returns how often the key with the hash has been requested
+func (s *sketch) estimate(h uint64) uint8 {
+	lowest := uint8(sketchMaxCount)
+
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < lowest {
+			lowest = c
+		}
+	}
+
+	return lowest
+}
+
+// index returns the counter for the hash in the given row, using double hashing to derive
+// an independent position for each row
+func (s *sketch) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*(h>>32|1)) & s.mask
+}
+
+// age halves every counter
+func (s *sketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+
+	s.additions /= 2
+}
+
+func (s *sketch) reset() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+
+	s.additions = 0
+}
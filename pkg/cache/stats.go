@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stats are the statistics of a cache since it was created
+type Stats struct {
+	// Hits is the number of times a key was found in the cache
+	Hits uint64
+	// Misses is the number of times a key was not found in the cache, including expired items
+	Misses uint64
+	// Evictions is the number of items evicted to make room for other items
+	Evictions uint64
+	// Expirations is the number of items removed because they expired
+	Expirations uint64
+	// Items is the number of items in the cache
+	Items int
+	// Cost is the total cost of the items in the cache
+	Cost int64
+}
+
+// HitRatio returns the proportion of lookups that found the key in the cache
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// StatsProvider is implemented by caches that record statistics
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// counters are the statistics recorded by a shard, they are atomic as hits can be recorded under the read lock
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+// Stats returns the statistics of the cache
+func (c *Keyed[K, V]) Stats() Stats {
+	var s Stats
+
+	for _, sh := range c.shards {
+		s.Hits += sh.counters.hits.Load()
+		s.Misses += sh.counters.misses.Load()
+		s.Evictions += sh.counters.evictions.Load()
+		s.Expirations += sh.counters.expirations.Load()
+	}
+
+	s.Items = c.Len()
+	s.Cost = c.Cost()
+
+	return s
+}
+
+// Collector reports the statistics of a cache to Prometheus, register it with
+// metrics.Server.RegisterCollectors or add it to an Instrumentation with WithCollector
+type Collector struct {
+	provider    StatsProvider
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	evictions   *prometheus.Desc
+	expirations *prometheus.Desc
+	items       *prometheus.Desc
+	cost        *prometheus.Desc
+}
+
+// NewCollector creates a collector for the cache in the namespace, with the name of the cache as the cache label
+func NewCollector(namespace, name string, provider StatsProvider) *Collector {
+	labels := prometheus.Labels{"cache": name}
+
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", metric), help, nil, labels)
+	}
+
+	return &Collector{
+		provider:    provider,
+		hits:        desc("hits_total", "Number of times a key was found in the cache"),
+		misses:      desc("misses_total", "Number of times a key was not found in the cache"),
+		evictions:   desc("evictions_total", "Number of items evicted to make room for other items"),
+		expirations: desc("expirations_total", "Number of items removed because they expired"),
+		items:       desc("items", "Number of items in the cache"),
+		cost:        desc("cost", "Total cost of the items in the cache"),
+	}
+}
+
+// Describe sends the descriptions of the cache metrics to the channel
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+	ch <- c.items
+	ch <- c.cost
+}
+
+// Collect sends the current statistics of the cache to the channel
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.provider.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(s.Expirations))
+	ch <- prometheus.MustNewConstMetric(c.items, prometheus.GaugeValue, float64(s.Items))
+	ch <- prometheus.MustNewConstMetric(c.cost, prometheus.GaugeValue, float64(s.Cost))
+}
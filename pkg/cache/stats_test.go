@@ -0,0 +1,61 @@
+package cache_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+	"gitlab.com/gobl/gobl/pkg/metrics"
+)
+
+func TestCache_Stats(t *testing.T) {
+	c := cache.New[int](cache.WithMaxEntries(2), cache.WithShards(1))
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+	c.Set("c", 3)
+	c.SetWithExpiry("d", 4, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	c.Get("d")
+
+	t.Run("Stats should count hits, misses, evictions and expirations", func(t *testing.T) {
+		s := c.Stats()
+
+		assert.Equal(t, uint64(2), s.Hits)
+		assert.Equal(t, uint64(2), s.Misses)
+		assert.Equal(t, uint64(2), s.Evictions)
+		assert.Equal(t, uint64(1), s.Expirations)
+		assert.Equal(t, 1, s.Items)
+		assert.Equal(t, int64(1), s.Cost)
+		assert.InDelta(t, 0.5, s.HitRatio(), 0.001)
+	})
+
+	t.Run("The collector should report the statistics to Prometheus", func(t *testing.T) {
+		collector := cache.NewCollector("test", "numbers", c)
+
+		expected := `
+# HELP test_cache_hits_total Number of times a key was found in the cache
+# TYPE test_cache_hits_total counter
+test_cache_hits_total{cache="numbers"} 2
+# HELP test_cache_items Number of items in the cache
+# TYPE test_cache_items gauge
+test_cache_items{cache="numbers"} 1
+`
+		err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "test_cache_hits_total", "test_cache_items")
+		require.NoError(t, err)
+		assert.Equal(t, 6, testutil.CollectAndCount(collector))
+	})
+
+	t.Run("The collector should be usable as instrumentation", func(t *testing.T) {
+		inst := metrics.NewInstrumentation("test").WithCollector(0, cache.NewCollector("test", "numbers", c))
+		assert.Len(t, inst.Collectors(), 1)
+	})
+}
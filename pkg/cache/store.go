@@ -0,0 +1,32 @@
+package cache
+
+import "time"
+
+// Store is the interface implemented by the in-process Cache and the distributed Redis cache,
+// so that the implementation can be swapped without changing the code that uses it
+type Store[T any] interface {
+	// Get returns the value for the given key if it exists and has not expired
+	Get(key string) (T, bool)
+	// Set sets the value for the given key with the default expiry
+	Set(key string, value T)
+	// SetWithExpiry sets the value for the given key with an expiry
+	SetWithExpiry(key string, value T, expiry time.Duration)
+	// Delete deletes the value for the given key
+	Delete(key string)
+	// Clear removes every item from the cache
+	Clear()
+	// Len returns the number of items in the cache
+	Len() int
+	// Keys returns the keys in the cache
+	Keys() []string
+}
+
+var (
+	_ Store[any] = (*Cache[any])(nil)
+	_ Store[any] = (*Redis[any])(nil)
+	_ Store[any] = (*Tiered[any])(nil)
+
+	_ Invalidator = (*RedisInvalidator)(nil)
+
+	_ StatsProvider = (*Cache[any])(nil)
+)
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/logger"
+)
+
+// DefaultInvalidationTimeout is the default time allowed to publish an invalidation
+const DefaultInvalidationTimeout = 5 * time.Second
+
+// Invalidator broadcasts the keys that have changed so that other replicas can drop them from their local cache
+type Invalidator interface {
+	// Invalidate tells the other replicas to drop the keys, no keys tells them to drop everything
+	Invalidate(ctx context.Context, keys ...string) error
+	// Listen calls fn with the keys invalidated by other replicas until the context is done
+	Listen(ctx context.Context, fn func(keys []string)) error
+}
+
+// TieredErrorFn is called when an invalidation could not be published
+type TieredErrorFn func(keys []string, err error)
+
+type tieredOptions struct {
+	// timeout is the time allowed to publish an invalidation
+	timeout time.Duration
+	// onError is called when an invalidation could not be published
+	onError TieredErrorFn
+}
+
+// TieredOption configures optional behaviour of a tiered cache
+type TieredOption func(*tieredOptions)
+
+// WithInvalidationTimeout sets the time allowed to publish an invalidation
+func WithInvalidationTimeout(timeout time.Duration) TieredOption {
+	return func(o *tieredOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithTieredErrorHook sets the function called when an invalidation could not be published.
+// By default the errors are logged.
+func WithTieredErrorHook(fn TieredErrorFn) TieredOption {
+	return func(o *tieredOptions) {
+		o.onError = fn
+	}
+}
+
+func defaultTieredOptions() tieredOptions {
+	return tieredOptions{
+		timeout: DefaultInvalidationTimeout,
+	}
+}
+
+// Tiered is a near cache with a local in-process cache (L1) in front of a shared cache (L2), e.g. Redis.
+// Reads are served from L1 when possible and fall back to L2, populating L1. Writes go to L2 then L1,
+// and the changed keys are broadcast with the Invalidator so other replicas drop their stale L1 entries.
+// Listen must be running to receive the invalidations from other replicas. Give L1 a short expiry to
+// bound how stale it can get if an invalidation is missed.
+type Tiered[T any] struct {
+	l1          *Cache[T]
+	l2          Store[T]
+	invalidator Invalidator
+	options     tieredOptions
+}
+
+// NewTiered creates a tiered cache from the local and shared caches
+func NewTiered[T any](l1 *Cache[T], l2 Store[T], invalidator Invalidator, opts ...TieredOption) *Tiered[T] {
+	def := defaultTieredOptions()
+	for _, o := range opts {
+		o(&def)
+	}
+
+	return &Tiered[T]{
+		l1:          l1,
+		l2:          l2,
+		invalidator: invalidator,
+		options:     def,
+	}
+}
+
+// Listen drops the keys invalidated by other replicas from the local cache until the context is done
+func (t *Tiered[T]) Listen(ctx context.Context) error {
+	return t.invalidator.Listen(ctx, func(keys []string) {
+		if len(keys) == 0 {
+			t.l1.Clear()
+			return
+		}
+
+		for _, k := range keys {
+			t.l1.Delete(k)
+		}
+	})
+}
+
+// Get returns the value from the local cache, or from the shared cache if it is not held locally
+func (t *Tiered[T]) Get(key string) (T, bool) {
+	if v, ok := t.l1.Get(key); ok {
+		return v, true
+	}
+
+	v, ok := t.l2.Get(key)
+	if ok {
+		t.l1.Set(key, v)
+	}
+
+	return v, ok
+}
+
+// Set sets the value in both caches with their default expiry and invalidates the key on other replicas
+func (t *Tiered[T]) Set(key string, value T) {
+	t.l2.Set(key, value)
+	t.l1.Set(key, value)
+	t.invalidate(key)
+}
+
+// SetWithExpiry sets the value in both caches with an expiry and invalidates the key on other replicas
+func (t *Tiered[T]) SetWithExpiry(key string, value T, expiry time.Duration) {
+	t.l2.SetWithExpiry(key, value, expiry)
+	t.l1.SetWithExpiry(key, value, expiry)
+	t.invalidate(key)
+}
+
+// Delete deletes the value from both caches and invalidates the key on other replicas
+func (t *Tiered[T]) Delete(key string) {
+	t.l2.Delete(key)
+	t.l1.Delete(key)
+	t.invalidate(key)
+}
+
+// Clear clears both caches and tells other replicas to clear their local caches
+func (t *Tiered[T]) Clear() {
+	t.l2.Clear()
+	t.l1.Clear()
+	t.invalidate()
+}
+
+// Len returns the number of items in the shared cache
+func (t *Tiered[T]) Len() int {
+	return t.l2.Len()
+}
+
+// Keys returns the keys in the shared cache
+func (t *Tiered[T]) Keys() []string {
+	return t.l2.Keys()
+}
+
+func (t *Tiered[T]) invalidate(keys ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.options.timeout)
+	defer cancel()
+
+	err := t.invalidator.Invalidate(ctx, keys...)
+	if err == nil {
+		return
+	}
+
+	if t.options.onError != nil {
+		t.options.onError(keys, err)
+		return
+	}
+
+	logger.Logger().Error("Could not publish cache invalidation", zap.Strings("keys", keys), zap.Error(err))
+}
@@ -0,0 +1,157 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/cache"
+)
+
+// bus delivers invalidations between the invalidators of replicas in the same process
+type bus struct {
+	mu        sync.Mutex
+	listeners map[*busInvalidator]func([]string)
+}
+
+type busInvalidator struct {
+	bus *bus
+}
+
+func (b *bus) invalidator() *busInvalidator {
+	return &busInvalidator{bus: b}
+}
+
+func (b *bus) listening() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.listeners)
+}
+
+func (i *busInvalidator) Invalidate(_ context.Context, keys ...string) error {
+	i.bus.mu.Lock()
+	defer i.bus.mu.Unlock()
+
+	for other, fn := range i.bus.listeners {
+		if other != i {
+			fn(keys)
+		}
+	}
+
+	return nil
+}
+
+func (i *busInvalidator) Listen(ctx context.Context, fn func([]string)) error {
+	i.bus.mu.Lock()
+	i.bus.listeners[i] = fn
+	i.bus.mu.Unlock()
+
+	<-ctx.Done()
+
+	return nil
+}
+
+func TestTiered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := &bus{listeners: make(map[*busInvalidator]func([]string))}
+	shared := cache.New[string]()
+
+	replicaA := cache.NewTiered[string](cache.New[string](), shared, b.invalidator())
+	replicaB := cache.NewTiered[string](cache.New[string](), shared, b.invalidator())
+
+	go replicaA.Listen(ctx) //nolint:errcheck
+	go replicaB.Listen(ctx) //nolint:errcheck
+
+	require.Eventually(t, func() bool { return b.listening() == 2 }, time.Second, time.Millisecond)
+
+	t.Run("Get should read through to the shared cache", func(t *testing.T) {
+		replicaA.Set("foo", "bar")
+
+		v, ok := replicaB.Get("foo")
+		assert.True(t, ok)
+		assert.Equal(t, "bar", v)
+	})
+
+	t.Run("Get should be served from the local cache", func(t *testing.T) {
+		shared.Set("foo", "changed without invalidation")
+
+		v, ok := replicaB.Get("foo")
+		assert.True(t, ok)
+		assert.Equal(t, "bar", v)
+	})
+
+	t.Run("Set should invalidate the key on other replicas", func(t *testing.T) {
+		replicaA.Set("foo", "baz")
+
+		v, ok := replicaB.Get("foo")
+		assert.True(t, ok)
+		assert.Equal(t, "baz", v)
+	})
+
+	t.Run("Delete should invalidate the key on other replicas", func(t *testing.T) {
+		replicaA.Delete("foo")
+
+		_, ok := replicaB.Get("foo")
+		assert.False(t, ok)
+	})
+
+	t.Run("Clear should clear the local caches of other replicas", func(t *testing.T) {
+		replicaA.Set("foo", "bar")
+		replicaA.Set("baz", "qux")
+		replicaB.Get("foo")
+		replicaB.Get("baz")
+
+		replicaA.Clear()
+
+		_, ok := replicaB.Get("foo")
+		assert.False(t, ok)
+		assert.Equal(t, 0, replicaB.Len())
+	})
+}
+
+func TestRedisInvalidator(t *testing.T) {
+	scaffold := setupRedis(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := cache.NewRedisInvalidator(scaffold.Rdb, cache.DefaultInvalidationChannel)
+	b := cache.NewRedisInvalidator(scaffold.Rdb, cache.DefaultInvalidationChannel)
+
+	received := make(chan []string, 2)
+	listen := func(inv *cache.RedisInvalidator) {
+		_ = inv.Listen(ctx, func(keys []string) { received <- keys })
+	}
+
+	go listen(a)
+	go listen(b)
+
+	// give the subscriptions time to be set up
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, a.Invalidate(ctx, "foo", "bar"))
+
+	t.Run("Other invalidators should receive the keys", func(t *testing.T) {
+		select {
+		case keys := <-received:
+			assert.Equal(t, []string{"foo", "bar"}, keys)
+		case <-time.After(time.Second):
+			t.Fatal("invalidation was not received")
+		}
+	})
+
+	t.Run("The publishing invalidator should ignore its own message", func(t *testing.T) {
+		select {
+		case keys := <-received:
+			t.Fatalf("unexpected invalidation %v", keys)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
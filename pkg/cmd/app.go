@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/io/strings"
+	"gitlab.com/gobl/gobl/pkg/logger"
+	"gitlab.com/gobl/gobl/pkg/property"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+// App runs a service from the command line. It owns its cobra command, Viper configuration and logger,
+// so that more than one service can be run in the same process, e.g. in tests.
+// The default app reads its configuration into the global Viper instance, so that it can be read with
+// config.Get, and replaces the global zap loggers. Other apps only change their own, see Config and Logger.
+type App struct {
+	// cfgFile, usage, short and long point to the deprecated package variables for the default app
+	cfgFile *string
+	viper   *viper.Viper
+	global  bool
+	log     *zap.Logger
+	ctx     context.Context //nolint:containedctx
+	service service.Service
+	state   service.State
+	// root is the default root command, override replaces it if it is set with SetRootCmd
+	root     *cobra.Command
+	override *cobra.Command
+	version  *cobra.Command
+	// initFuncs are run by cobra once the flags have been parsed, while the app is executing its root command
+	initFuncs   []func()
+	defaultInit bool
+	executing   atomic.Pointer[cobra.Command]
+	usage       *string
+	short       *string
+	long        *string
+	// gracePeriod is how long the cleanup, or a reload, has to complete, reload is called on SIGHUP and
+	// reloading is set while it runs
+	gracePeriod time.Duration
+	reload      ReloadFunc
+	reloading   atomic.Bool
+	// exit is called to force the service to exit, it is replaced in tests
+	exit func(code int)
+}
+
+// NewApp creates an app with a root command that runs the service, and the version command
+func NewApp() *App {
+	a := &App{
+		cfgFile: new(string),
+		viper:   viper.New(),
+		usage:   new(string),
+		short:   new(string),
+		long:    new(string),
+	}
+	a.setup()
+
+	return a
+}
+
+// newDefaultApp creates the app used by the package functions, which uses the global Viper instance and
+// zap loggers, and the package variables
+func newDefaultApp() *App {
+	a := &App{
+		cfgFile: &CfgFile,
+		viper:   viper.GetViper(),
+		global:  true,
+		usage:   &Usage,
+		short:   &ShortDescription,
+		long:    &LongDescription,
+	}
+	a.setup()
+
+	return a
+}
+
+// setup creates the root and version commands of the app
+func (a *App) setup() {
+	a.log = logger.ConsoleLogger()
+	a.exit = os.Exit
+	a.version = newVersionCmd(a.viper)
+
+	a.root = &cobra.Command{
+		Run: a.startService,
+	}
+	a.root.PersistentFlags().StringVar(a.cfgFile, "config", "", "configuration file to use for the service")
+	a.root.AddCommand(a.version)
+
+	// cobra only has global initialisers, which run for every command whatever its hooks, so the app only
+	// runs its own functions while it is executing its root command
+	cobra.OnInitialize(a.initialise)
+}
+
+// SetCliProperties sets the Cobra command CLI properties so that information
+// on how to use the application is provided when help is needed.
+func (a *App) SetCliProperties(usage, short, long string) {
+	*a.usage = usage
+	*a.short = short
+	*a.long = long
+}
+
+// SetRootCmd sets an override root command to run instead of the default root command
+func (a *App) SetRootCmd(c *cobra.Command) {
+	a.override = c
+	a.override.PersistentFlags().StringVar(a.cfgFile, "config", "", "configuration file to use for the service")
+}
+
+// Command returns the root command that is run by Execute
+func (a *App) Command() *cobra.Command {
+	if a.override != nil {
+		return a.override
+	}
+
+	return a.root
+}
+
+// ConfigFile returns the configuration file set with the --config flag
+func (a *App) ConfigFile() string {
+	return *a.cfgFile
+}
+
+// Config returns the Viper instance the configuration of the app is read into, use config.In to read it
+func (a *App) Config() *viper.Viper {
+	return a.viper
+}
+
+// Logger returns the logger of the app
+func (a *App) Logger() *zap.Logger {
+	return a.log
+}
+
+// AddCommand allows you to add a sub-command to the root command
+func (a *App) AddCommand(commands ...*cobra.Command) {
+	a.Command().AddCommand(commands...)
+}
+
+// SetupCobraInit sets the cobra initialisation functions, InitConfig is used if none have been added
+func (a *App) SetupCobraInit() {
+	if a.initFuncs == nil {
+		a.initFuncs = append(a.initFuncs, a.InitConfig)
+	}
+}
+
+// AddBootstrapInitFuncs adds initialisation functions that are run by Cobra
+// on application initialisation
+func (a *App) AddBootstrapInitFuncs(includeDefaultInit bool, fs ...func()) {
+	if includeDefaultInit && !a.defaultInit {
+		a.defaultInit = true
+		// make sure we call the default init function first
+		initFns := []func(){a.InitConfig}
+		a.initFuncs = append(append(initFns, a.initFuncs...), fs...)
+		return
+	}
+
+	a.initFuncs = append(a.initFuncs, fs...)
+	a.SetupCobraInit()
+}
+
+// initialise runs the initialisation functions when cobra initialises a command, if the app is executing
+// its root command
+func (a *App) initialise() {
+	if root := a.executing.Load(); root == nil || root != a.Command() {
+		return
+	}
+
+	for _, f := range a.initFuncs {
+		f()
+	}
+}
+
+// InitConfig is the function called to initialise the service configuration file
+func (a *App) InitConfig() {
+	if *a.cfgFile != "" {
+		a.viper.SetConfigFile(*a.cfgFile)
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatal("could not get user home directory", zap.Error(err))
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			log.Fatal("could not get the current executable name", zap.Error(err))
+		}
+
+		executablePath := strings.SplitAndTrimSpace(executable, string(os.PathSeparator))
+
+		appName := executablePath[len(executablePath)-1]
+
+		a.viper.AddConfigPath(".")
+		a.viper.AddConfigPath("./config")
+		a.viper.AddConfigPath(fmt.Sprintf("%s/.config/%s", home, appName))
+		a.viper.SetConfigName("configuration")
+	}
+
+	a.viper.AutomaticEnv()
+
+	if err := a.viper.ReadInConfig(); err != nil {
+		//nolint:forbidigo
+		fmt.Printf("could not read application configuration file: %s\n\n", err)
+	} else {
+		a.log.Debug("using configuration", zap.String("file-path", a.viper.ConfigFileUsed()))
+	}
+
+	a.SetupLogger()
+}
+
+// SetupLogger sets up the logging configuration based on defaults or properties set in the
+// application configuration file. Only the default app replaces the global zap loggers.
+func (a *App) SetupLogger() {
+	if !a.global {
+		a.log = logger.NewIn(a.viper)
+		return
+	}
+
+	a.log = logger.Get(logger.ApplicationLogLevel(), logger.ConfiguredLumberjackLogger())
+	zap.ReplaceGlobals(a.log)
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately, then runs the
+// command, exiting if it fails. This is called by main.main(). It only needs to happen once.
+func (a *App) Execute(ctx context.Context, svc service.Service, state service.State) {
+	if err := a.Run(ctx, svc, state); err != nil {
+		//nolint:forbidigo
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the command in the same way as Execute, but returns the error instead of exiting
+func (a *App) Run(ctx context.Context, svc service.Service, state service.State) error {
+	a.ctx = ctx
+	a.service = svc
+	a.state = state
+
+	runCmd := a.Command()
+
+	runCmd.Use = a.cliProperty(property.KeyUsage, *a.usage)
+	runCmd.Short = a.cliProperty(property.KeyShortDesc, *a.short)
+	runCmd.Long = a.cliProperty(property.KeyLongDesc, *a.long)
+
+	// make sure we setup the cobra initialisation properly
+	a.SetupCobraInit()
+
+	a.executing.Store(runCmd)
+	defer a.executing.Store(nil)
+
+	return runCmd.Execute()
+}
+
+// cliProperty returns the property of the service if it has been set, otherwise the value set on the app
+func (a *App) cliProperty(name, value string) string {
+	if a.service == nil {
+		return value
+	}
+
+	p, err := a.service.GetProperty(name)
+	if err != nil {
+		return value
+	}
+
+	return p.NonEmptyString(value)
+}
+
+func (a *App) startService(*cobra.Command, []string) {
+	runCtx, cancel := context.WithCancel(a.ctx)
+	defer cancel()
+
+	// the channel is buffered for a second signal so that it is not missed while the first one is handled
+	signalCh := make(chan os.Signal, 2)
+	signal.Notify(signalCh, a.signals()...)
+	defer signal.Stop(signalCh)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go a.handleSignals(runCtx, cancel, signalCh, done)
+
+	if err := a.service.Init(runCtx, a.state); err != nil {
+		log.Fatal("could not initialise the application", zap.Error(err))
+	}
+
+	if a.service.RunFunction() != nil {
+		if err := a.service.RunFunction()(runCtx, a.state); err != nil {
+			a.log.Error("Command failed", zap.Error(err))
+		}
+	} else {
+		a.log.Info("Starting service. Ctrl-C to terminate")
+		<-runCtx.Done()
+	}
+
+	cancel()
+
+	if err := a.cleanup(a.shutdownGracePeriod()); err != nil {
+		a.log.Fatal("could not execute cleanup", zap.Error(err))
+	}
+}
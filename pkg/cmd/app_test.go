@@ -0,0 +1,147 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/bootstrap"
+	"gitlab.com/gobl/gobl/pkg/cmd"
+	"gitlab.com/gobl/gobl/pkg/config"
+	"gitlab.com/gobl/gobl/pkg/property"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+type appState struct {
+	ran bool
+}
+
+func newApp(initialised *[]string, name string) *cmd.App {
+	a := cmd.NewApp()
+	a.AddBootstrapInitFuncs(false, func() { *initialised = append(*initialised, name) })
+	a.Command().SetArgs([]string{})
+
+	return a
+}
+
+func run(_ context.Context, state service.State) error {
+	s, _ := state.(*appState)
+	s.ran = true
+
+	return nil
+}
+
+func TestApp(t *testing.T) {
+	t.Run("Apps should run their own service and initialisation functions", func(t *testing.T) {
+		var initialised []string
+
+		a, b := newApp(&initialised, "a"), newApp(&initialised, "b")
+		stateA, stateB := &appState{}, &appState{}
+
+		require.NoError(t, a.Run(context.Background(), bootstrap.New().WithRunFunc(run), stateA))
+		assert.True(t, stateA.ran)
+		assert.False(t, stateB.ran)
+		assert.Equal(t, []string{"a"}, initialised)
+
+		require.NoError(t, b.Run(context.Background(), bootstrap.New().WithRunFunc(run), stateB))
+		assert.True(t, stateB.ran)
+		assert.Equal(t, []string{"a", "b"}, initialised)
+	})
+
+	t.Run("Run should use the CLI properties of the service", func(t *testing.T) {
+		var initialised []string
+
+		a := newApp(&initialised, "a")
+		a.SetCliProperties("app", "short", "long")
+
+		svc := bootstrap.New().
+			WithRunFunc(run).
+			AddProperty(property.KeyShortDesc, property.StringProperty(property.KeyShortDesc, "from the service"))
+
+		require.NoError(t, a.Run(context.Background(), svc, &appState{}))
+		assert.Equal(t, "app", a.Command().Use)
+		assert.Equal(t, "from the service", a.Command().Short)
+	})
+
+	t.Run("AddCommand should add the command to the root command of the app", func(t *testing.T) {
+		a, b := cmd.NewApp(), cmd.NewApp()
+		a.AddCommand(&cobra.Command{Use: "extra"})
+
+		assert.True(t, hasCommand(a, "extra"))
+		assert.False(t, hasCommand(b, "extra"))
+	})
+
+	t.Run("Apps should read their configuration into their own Viper instance", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "configuration.yaml")
+		require.NoError(t, os.WriteFile(file, []byte("version: 1.2.3\nlog:\n  filepath: "+filepath.Join(dir, "app.log")+"\n"), 0o600))
+
+		a, b := cmd.NewApp(), cmd.NewApp()
+		a.AddBootstrapInitFuncs(true)
+		a.Command().SetArgs([]string{"--config", file})
+
+		require.NoError(t, a.Run(context.Background(), bootstrap.New().WithRunFunc(run), &appState{}))
+		assert.Equal(t, file, a.ConfigFile())
+		assert.Equal(t, "1.2.3", config.In(a.Config(), config.VersionKey).String(""))
+		assert.Empty(t, config.In(b.Config(), config.VersionKey).String(""))
+		assert.Empty(t, config.Get(config.VersionKey).String(""))
+	})
+
+	t.Run("SetRootCmd should run the initialisation functions before the hook of the command", func(t *testing.T) {
+		var calls []string
+
+		a := newApp(&calls, "init")
+		a.SetRootCmd(&cobra.Command{
+			Use:              "root",
+			PersistentPreRun: func(*cobra.Command, []string) { calls = append(calls, "hook") },
+			Run:              func(*cobra.Command, []string) { calls = append(calls, "run") },
+		})
+		a.Command().SetArgs([]string{})
+
+		require.NoError(t, a.Run(context.Background(), bootstrap.New(), &appState{}))
+		assert.Equal(t, []string{"init", "hook", "run"}, calls)
+	})
+
+	t.Run("Sub-commands with their own hook should run the initialisation functions", func(t *testing.T) {
+		var calls []string
+
+		a := newApp(&calls, "init")
+		a.Command().PersistentPreRun = func(*cobra.Command, []string) { calls = append(calls, "root hook") }
+		a.AddCommand(&cobra.Command{
+			Use:              "sub",
+			PersistentPreRun: func(*cobra.Command, []string) { calls = append(calls, "sub hook") },
+			Run:              func(*cobra.Command, []string) { calls = append(calls, "sub") },
+		})
+		a.Command().SetArgs([]string{"sub"})
+
+		require.NoError(t, a.Run(context.Background(), bootstrap.New(), &appState{}))
+		assert.Equal(t, []string{"init", "sub hook", "sub"}, calls)
+	})
+
+	t.Run("SetCliProperties should set the package variables of the default app", func(t *testing.T) {
+		usage, short, long := cmd.Usage, cmd.ShortDescription, cmd.LongDescription
+		defer cmd.SetCliProperties(usage, short, long)
+
+		cmd.SetCliProperties("app", "short", "long")
+
+		assert.Equal(t, "app", cmd.Usage)
+		assert.Equal(t, "short", cmd.ShortDescription)
+		assert.Equal(t, "long", cmd.LongDescription)
+		assert.True(t, hasCommand(cmd.Default(), cmd.Version.Name()))
+	})
+}
+
+func hasCommand(a *cmd.App, name string) bool {
+	for _, c := range a.Command().Commands() {
+		if c.Name() == name {
+			return true
+		}
+	}
+
+	return false
+}
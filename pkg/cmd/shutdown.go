@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/config"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+// DefaultShutdownGracePeriod is how long the cleanup functions have to complete unless the grace period
+// is set with SetShutdownGracePeriod or the service.shutdown-grace-period configuration
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// ErrShutdownTimeout is returned when the cleanup functions do not complete within the shutdown grace period
+var ErrShutdownTimeout = errors.New("cleanup did not complete within the shutdown grace period")
+
+// ReloadFunc is called when the service receives SIGHUP, e.g. to reload its configuration
+type ReloadFunc func(ctx context.Context, state service.State) error
+
+// SetShutdownGracePeriod sets how long the cleanup functions have to complete once the service has been
+// asked to stop, after which the service exits without waiting for them. A grace period of 0 uses the
+// service.shutdown-grace-period configuration, or DefaultShutdownGracePeriod if it is not set.
+func (a *App) SetShutdownGracePeriod(d time.Duration) {
+	a.gracePeriod = d
+}
+
+// SetReloadFunc sets the function that is called when the service receives SIGHUP. Without a reload function
+// SIGHUP is not caught, and terminates the service.
+func (a *App) SetReloadFunc(fn ReloadFunc) {
+	a.reload = fn
+}
+
+// signals returns the signals handled by the service, SIGHUP is only handled when there is a reload function
+func (a *App) signals() []os.Signal {
+	if a.reload == nil {
+		return []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	return []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+}
+
+// shutdownGracePeriod returns the shutdown grace period that has been set or configured
+func (a *App) shutdownGracePeriod() time.Duration {
+	if a.gracePeriod > 0 {
+		return a.gracePeriod
+	}
+
+	return config.In(a.viper, config.ShutdownGracePeriodKey).Duration(DefaultShutdownGracePeriod)
+}
+
+// handleSignals cancels the root context on the first SIGINT or SIGTERM, so that everything started with it
+// stops, and forces the service to exit on the second one in case the cleanup is stuck. SIGHUP calls the
+// reload function in the background, unless it is already running, so that a slow reload does not delay
+// the other signals. It returns once done is closed.
+func (a *App) handleSignals(ctx context.Context, cancel context.CancelFunc, signalCh <-chan os.Signal, done <-chan struct{}) {
+	stopping := false
+
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-signalCh:
+			switch {
+			case sig == syscall.SIGHUP:
+				if stopping || a.reload == nil {
+					continue
+				}
+
+				if !a.reloading.CompareAndSwap(false, true) {
+					a.log.Warn("Caught signal while reloading, ignoring it", zap.String("signal", sig.String()))
+					continue
+				}
+
+				a.log.Info("Caught signal, reloading", zap.String("signal", sig.String()))
+
+				go a.runReload(ctx)
+			case !stopping:
+				stopping = true
+
+				a.log.Warn("Caught signal, terminating", zap.String("signal", sig.String()))
+				cancel()
+			default:
+				a.log.Error("Caught second signal, forcing exit", zap.String("signal", sig.String()))
+				a.exit(1)
+			}
+		}
+	}
+}
+
+// runReload calls the reload function with a context that is done when the root context is cancelled or
+// the shutdown grace period has passed, so that a stuck reload does not keep running
+func (a *App) runReload(ctx context.Context) {
+	defer a.reloading.Store(false)
+
+	ctx, cancel := context.WithTimeout(ctx, a.shutdownGracePeriod())
+	defer cancel()
+
+	if err := a.reload(ctx, a.state); err != nil {
+		a.log.Error("Reload failed", zap.Error(err))
+	}
+}
+
+// cleanup runs the cleanup functions of the service, giving up once the grace period has passed. Services
+// that implement service.ContextCleaner are given a context that is done when the grace period ends.
+func (a *App) cleanup(grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		if c, ok := a.service.(service.ContextCleaner); ok {
+			done <- c.CleanupContext(ctx, a.state)
+			return
+		}
+
+		done <- a.service.Cleanup(a.state)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w of %s", ErrShutdownTimeout, grace)
+	}
+}
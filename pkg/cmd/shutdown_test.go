@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+func TestHandleSignals(t *testing.T) {
+	a := NewApp()
+	a.SetShutdownGracePeriod(time.Minute)
+
+	exited := make(chan int, 1)
+	a.exit = func(code int) { exited <- code }
+
+	reloading := make(chan context.Context, 2)
+	release := make(chan struct{})
+
+	a.SetReloadFunc(func(ctx context.Context, _ service.State) error {
+		reloading <- ctx
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signalCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	defer close(done)
+
+	go a.handleSignals(ctx, cancel, signalCh, done)
+
+	t.Run("SIGHUP should call the reload function with a context bounded by the grace period", func(t *testing.T) {
+		signalCh <- syscall.SIGHUP
+
+		select {
+		case reloadCtx := <-reloading:
+			deadline, ok := reloadCtx.Deadline()
+			require.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+		case <-time.After(time.Second):
+			t.Fatal("reload function was not called")
+		}
+
+		assert.NoError(t, ctx.Err())
+	})
+
+	t.Run("SIGHUP should be ignored while the reload function is running", func(t *testing.T) {
+		signalCh <- syscall.SIGHUP
+
+		select {
+		case <-reloading:
+			t.Fatal("reload function was called while it was running")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("The first signal should cancel the root context while the reload function is running", func(t *testing.T) {
+		signalCh <- syscall.SIGTERM
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("context was not cancelled")
+		}
+
+		assert.Empty(t, exited)
+		close(release)
+	})
+
+	t.Run("The second signal should force the service to exit", func(t *testing.T) {
+		signalCh <- syscall.SIGINT
+
+		select {
+		case code := <-exited:
+			assert.Equal(t, 1, code)
+		case <-time.After(time.Second):
+			t.Fatal("service did not exit")
+		}
+	})
+}
+
+// cleanupService is a service that only implements Cleanup
+type cleanupService struct {
+	service.Service
+	cleanup service.CleanupFunc
+}
+
+func (s cleanupService) Cleanup(state service.State) error {
+	return s.cleanup(state)
+}
+
+func TestSignals(t *testing.T) {
+	t.Run("signals should not include SIGHUP without a reload function", func(t *testing.T) {
+		assert.Equal(t, []os.Signal{syscall.SIGINT, syscall.SIGTERM}, NewApp().signals())
+	})
+
+	t.Run("signals should include SIGHUP with a reload function", func(t *testing.T) {
+		a := NewApp()
+		a.SetReloadFunc(func(context.Context, service.State) error { return nil })
+
+		assert.Equal(t, []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}, a.signals())
+	})
+}
+
+// contextCleanupService is a service that implements service.ContextCleaner
+type contextCleanupService struct {
+	service.Service
+	cleanup func(ctx context.Context, state service.State) error
+}
+
+func (s contextCleanupService) CleanupContext(ctx context.Context, state service.State) error {
+	return s.cleanup(ctx, state)
+}
+
+func TestCleanup(t *testing.T) {
+	t.Run("cleanup should return the errors of the cleanup functions", func(t *testing.T) {
+		errFailed := errors.New("failed")
+		a := NewApp()
+		a.service = cleanupService{cleanup: func(service.State) error { return errFailed }}
+
+		require.ErrorIs(t, a.cleanup(time.Second), errFailed)
+	})
+
+	t.Run("cleanup should give up once the grace period has passed", func(t *testing.T) {
+		a := NewApp()
+		a.service = cleanupService{cleanup: func(service.State) error {
+			time.Sleep(time.Second)
+			return nil
+		}}
+
+		start := time.Now()
+
+		require.ErrorIs(t, a.cleanup(10*time.Millisecond), ErrShutdownTimeout)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("cleanup should pass a context that is done when the grace period ends", func(t *testing.T) {
+		var deadline time.Time
+
+		a := NewApp()
+		a.service = contextCleanupService{cleanup: func(ctx context.Context, _ service.State) error {
+			deadline, _ = ctx.Deadline()
+			return nil
+		}}
+
+		require.NoError(t, a.cleanup(time.Minute))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+
+	t.Run("shutdownGracePeriod should default to DefaultShutdownGracePeriod", func(t *testing.T) {
+		a := NewApp()
+		assert.Equal(t, DefaultShutdownGracePeriod, a.shutdownGracePeriod())
+
+		a.SetShutdownGracePeriod(time.Minute)
+		assert.Equal(t, time.Minute, a.shutdownGracePeriod())
+	})
+}
@@ -9,21 +9,15 @@ import (
 	"gitlab.com/gobl/gobl/pkg/config"
 )
 
-// Version returns the current version of the application
-//
-//nolint:gochecknoglobals,forbidigo
-var Version = &cobra.Command{
-	Use:   "version",
-	Short: "Current version",
-	Long:  "Get the current version number of the service",
-	Run: func(*cobra.Command, []string) {
-		fmt.Printf("Version: %s\n", viper.GetString(config.VersionKey))
-	},
-}
-
-// init is used by Cobra to initialise a command
-//
-//nolint:gochecknoinits
-func init() {
-	AddCommand(Version)
+// newVersionCmd returns the command that prints the current version of the application in the configuration
+func newVersionCmd(v *viper.Viper) *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Current version",
+		Long:  "Get the current version number of the service",
+		Run: func(*cobra.Command, []string) {
+			//nolint:forbidigo
+			fmt.Printf("Version: %s\n", v.GetString(config.VersionKey))
+		},
+	}
 }
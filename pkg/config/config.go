@@ -27,6 +27,8 @@ const (
 	LogFileMaxAge = "log.max-age"
 	// LogFileCompress is the configuration key for retrieving the log file compression configuration
 	LogFileCompress = "log.compress"
+	// ShutdownGracePeriodKey is the configuration key for retrieving how long the service has to clean up when it is stopped
+	ShutdownGracePeriodKey = "service.shutdown-grace-period"
 )
 
 var (
@@ -36,20 +38,27 @@ var (
 // Config is a helper for retrieving properties that have been created in the application's Viper
 // configuration file and making it easy to get the data in the right type
 type Config struct {
+	v    *viper.Viper
 	path []string
 }
 
-// Get returns the configuration at the specified path
+// Get returns the configuration at the specified path in the global Viper instance
 func Get(path ...string) *Config {
-	return &Config{path: path}
+	return In(viper.GetViper(), path...)
+}
+
+// In returns the configuration at the specified path in the given Viper instance, e.g. the configuration
+// of a cmd.App that is not the default app
+func In(v *viper.Viper, path ...string) *Config {
+	return &Config{v: v, path: path}
 }
 
 // String returns the string value of the property or returns the provided default
 func (c *Config) String(d string) string {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetString(k)
+	if c.v.IsSet(k) {
+		return c.v.GetString(k)
 	}
 
 	return d
@@ -59,8 +68,8 @@ func (c *Config) String(d string) string {
 func (c *Config) Int(d int) int {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetInt(k)
+	if c.v.IsSet(k) {
+		return c.v.GetInt(k)
 	}
 
 	return d
@@ -70,8 +79,8 @@ func (c *Config) Int(d int) int {
 func (c *Config) Int8(d int8) int8 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		v := viper.GetInt32(k)
+	if c.v.IsSet(k) {
+		v := c.v.GetInt32(k)
 
 		if v >= math.MinInt8 && v <= math.MaxInt8 {
 			return int8(v)
@@ -85,8 +94,8 @@ func (c *Config) Int8(d int8) int8 {
 func (c *Config) Int16(d int16) int16 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		v := viper.GetInt32(k)
+	if c.v.IsSet(k) {
+		v := c.v.GetInt32(k)
 
 		if v >= math.MinInt16 && v <= math.MaxInt16 {
 			return int16(v)
@@ -100,8 +109,8 @@ func (c *Config) Int16(d int16) int16 {
 func (c *Config) Int32(d int32) int32 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetInt32(k)
+	if c.v.IsSet(k) {
+		return c.v.GetInt32(k)
 	}
 
 	return d
@@ -111,8 +120,8 @@ func (c *Config) Int32(d int32) int32 {
 func (c *Config) Int64(d int64) int64 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetInt64(k)
+	if c.v.IsSet(k) {
+		return c.v.GetInt64(k)
 	}
 
 	return d
@@ -122,8 +131,8 @@ func (c *Config) Int64(d int64) int64 {
 func (c *Config) Value(d interface{}) interface{} {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.Get(k)
+	if c.v.IsSet(k) {
+		return c.v.Get(k)
 	}
 
 	return d
@@ -133,8 +142,8 @@ func (c *Config) Value(d interface{}) interface{} {
 func (c *Config) Bool(d bool) bool {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetBool(k)
+	if c.v.IsSet(k) {
+		return c.v.GetBool(k)
 	}
 
 	return d
@@ -144,8 +153,8 @@ func (c *Config) Bool(d bool) bool {
 func (c *Config) Float64(d float64) float64 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetFloat64(k)
+	if c.v.IsSet(k) {
+		return c.v.GetFloat64(k)
 	}
 
 	return d
@@ -155,8 +164,8 @@ func (c *Config) Float64(d float64) float64 {
 func (c *Config) Float32(d float32) float32 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		v := viper.GetFloat64(k)
+	if c.v.IsSet(k) {
+		v := c.v.GetFloat64(k)
 
 		if v >= -math.MaxFloat32 && v <= math.MaxFloat32 {
 			return float32(v)
@@ -170,8 +179,8 @@ func (c *Config) Float32(d float32) float32 {
 func (c *Config) StringMap(d map[string]interface{}) map[string]interface{} {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetStringMap(k)
+	if c.v.IsSet(k) {
+		return c.v.GetStringMap(k)
 	}
 
 	return d
@@ -181,8 +190,8 @@ func (c *Config) StringMap(d map[string]interface{}) map[string]interface{} {
 func (c *Config) StringMapString(d map[string]string) map[string]string {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetStringMapString(k)
+	if c.v.IsSet(k) {
+		return c.v.GetStringMapString(k)
 	}
 
 	return d
@@ -192,8 +201,8 @@ func (c *Config) StringMapString(d map[string]string) map[string]string {
 func (c *Config) StringSlice(d []string) []string {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetStringSlice(k)
+	if c.v.IsSet(k) {
+		return c.v.GetStringSlice(k)
 	}
 
 	return d
@@ -203,8 +212,8 @@ func (c *Config) StringSlice(d []string) []string {
 func (c *Config) Time(d time.Time) time.Time {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetTime(k)
+	if c.v.IsSet(k) {
+		return c.v.GetTime(k)
 	}
 
 	return d
@@ -214,8 +223,8 @@ func (c *Config) Time(d time.Time) time.Time {
 func (c *Config) Duration(d time.Duration) time.Duration {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetDuration(k)
+	if c.v.IsSet(k) {
+		return c.v.GetDuration(k)
 	}
 
 	return d
@@ -225,8 +234,8 @@ func (c *Config) Duration(d time.Duration) time.Duration {
 func (c *Config) Uint(d uint) uint {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetUint(k)
+	if c.v.IsSet(k) {
+		return c.v.GetUint(k)
 	}
 
 	return d
@@ -236,8 +245,8 @@ func (c *Config) Uint(d uint) uint {
 func (c *Config) Uint8(d uint8) uint8 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		v := viper.GetUint(k)
+	if c.v.IsSet(k) {
+		v := c.v.GetUint(k)
 
 		if v <= math.MaxUint8 {
 			return uint8(v)
@@ -251,8 +260,8 @@ func (c *Config) Uint8(d uint8) uint8 {
 func (c *Config) Uint16(d uint16) uint16 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		v := viper.GetUint(k)
+	if c.v.IsSet(k) {
+		v := c.v.GetUint(k)
 
 		if v <= math.MaxUint16 {
 			return uint16(v)
@@ -266,8 +275,8 @@ func (c *Config) Uint16(d uint16) uint16 {
 func (c *Config) Uint32(d uint32) uint32 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetUint32(k)
+	if c.v.IsSet(k) {
+		return c.v.GetUint32(k)
 	}
 
 	return d
@@ -277,8 +286,8 @@ func (c *Config) Uint32(d uint32) uint32 {
 func (c *Config) Uint64(d uint64) uint64 {
 	k := strings.MkString(".", c.path...)
 
-	if viper.IsSet(k) {
-		return viper.GetUint64(k)
+	if c.v.IsSet(k) {
+		return c.v.GetUint64(k)
 	}
 
 	return d
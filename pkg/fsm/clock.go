@@ -0,0 +1,145 @@
+package fsm
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers to the state machine so that timeouts can be
+// tested deterministically with a FakeClock
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once the duration has elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer created by a Clock
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if the timer has already fired or been stopped.
+	Stop() bool
+}
+
+type realClock struct{}
+
+// RealClock returns a Clock that uses the time package
+func RealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// FakeClock is a Clock whose time only moves when Advance is called
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	fn       func()
+	stopped  bool
+}
+
+// NewFakeClock creates a FakeClock set to the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the fake clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc creates a timer that calls f when the fake clock has been advanced past the duration
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), fn: f}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+// Advance moves the fake clock forward by the duration and calls the functions of any timers
+// that are due, in deadline order. The functions are called synchronously so that when Advance
+// returns every timer that was due has fired.
+//
+// The timers of a state machine pass their timeout event to the machine, so Advance waits for a
+// running machine to receive them, and returns straight away for a machine that has stopped. Advance
+// must not be called from the machine's goroutine, e.g. from a hook, or while the machine is waiting
+// on the caller, as the machine cannot receive the events until it has finished processing.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+
+	var due, pending []*fakeTimer
+
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case !t.deadline.After(c.now):
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].deadline.Before(due[j].deadline)
+	})
+
+	for _, t := range due {
+		t.fn()
+	}
+}
+
+// Timers returns the number of timers that have not yet fired or been stopped
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+
+	for _, t := range c.timers {
+		if !t.stopped {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	if t.stopped {
+		return false
+	}
+
+	for _, pending := range t.clock.timers {
+		if pending == t {
+			t.stopped = true
+			return true
+		}
+	}
+
+	return false
+}
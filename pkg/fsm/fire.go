@@ -0,0 +1,61 @@
+package fsm
+
+import "context"
+
+type fireRequest struct {
+	event Event
+	reply chan fireResult
+}
+
+type fireResult struct {
+	state State
+	err   error
+}
+
+// Fire passes the event to the running state machine and waits for it to be processed, returning
+// the state the machine is in afterwards or the error that processing the event resulted in.
+// Errors returned by Fire are not published on the error channel, but the error hooks are called.
+// Fire is safe to use concurrently with the event channel passed to Run, events are processed one
+// at a time in the order they are received by the machine. Fire blocks until the machine is running,
+// and returns ErrMachineStopped once it has stopped.
+//
+// Fire must not be called from the machine's own goroutine, i.e. from a state's Execute, a guard, an
+// entry or exit action, or a transition or error hook. The machine cannot receive the event until it has
+// finished processing the current one, so such a call blocks until its context is done and the machine
+// is stuck until then. Send the event on the event channel from another goroutine instead.
+func (m *Machine) Fire(ctx context.Context, event Event) (State, error) {
+	state, _, err := m.fire(ctx, event)
+	return state, err
+}
+
+// fire passes the event to the running state machine and also returns whether the machine accepted
+// the event, so callers can tell an event that was never processed from one that stopped the machine
+func (m *Machine) fire(ctx context.Context, event Event) (State, bool, error) {
+	req := fireRequest{
+		event: event,
+		reply: make(chan fireResult, 1),
+	}
+
+	select {
+	case m.fireCh <- req:
+	case <-m.stopCh:
+		return nil, false, ErrMachineStopped
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	select {
+	case res := <-req.reply:
+		return res.state, true, res.err
+	case <-m.stopCh:
+		// the reply is sent before the machine stops, so check for it before giving up
+		select {
+		case res := <-req.reply:
+			return res.state, true, res.err
+		default:
+			return nil, true, ErrMachineStopped
+		}
+	case <-ctx.Done():
+		return nil, true, ctx.Err()
+	}
+}
@@ -0,0 +1,143 @@
+package fsm_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+func TestMachine_Fire(t *testing.T) {
+	table, err := fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Transition(locked, coin, nil, unlocked).
+		Transition(unlocked, coin, nil, unlocked).
+		Transition(unlocked, push, nil, locked).
+		Build()
+	require.NoError(t, err)
+
+	m, _, err := fsm.NewFromTable(uuid.New(), "turnstile", table)
+	require.NoError(t, err)
+
+	t.Run("Fire should wait for the machine to run", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := m.Fire(ctx, newEvent(coin))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	events := make(chan fsm.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(ctx, events, func() error { return nil })
+	}()
+
+	t.Run("Fire should return the resulting state", func(t *testing.T) {
+		state, err := m.Fire(context.Background(), newEvent(coin))
+		require.NoError(t, err)
+		assert.Equal(t, unlocked, state.Description())
+	})
+
+	t.Run("Fire should return the error for the event", func(t *testing.T) {
+		state, err := m.Fire(context.Background(), newEvent(fail))
+		assert.ErrorIs(t, err, fsm.ErrEventNotHandled)
+		assert.Equal(t, unlocked, state.Description())
+	})
+
+	t.Run("Fire should be safe to use alongside the event channel", func(t *testing.T) {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+
+			go func() {
+				defer wg.Done()
+				_, err := m.Fire(context.Background(), newEvent(coin))
+				assert.NoError(t, err)
+			}()
+
+			go func() {
+				defer wg.Done()
+				events <- newEvent(coin)
+			}()
+		}
+
+		wg.Wait()
+
+		state, err := m.Fire(context.Background(), newEvent(push))
+		require.NoError(t, err)
+		assert.Equal(t, locked, state.Description())
+		assert.Equal(t, 21, state.(*testState).Coins)
+	})
+
+	cancel()
+	require.NoError(t, <-done)
+
+	t.Run("Fire should return an error once the machine has stopped", func(t *testing.T) {
+		_, err := m.Fire(context.Background(), newEvent(coin))
+		assert.ErrorIs(t, err, fsm.ErrMachineStopped)
+	})
+}
+
+func TestMachine_FireFromHook(t *testing.T) {
+	var m *fsm.Machine
+
+	fired := make(chan error, 1)
+
+	// firing from the machine's goroutine cannot be processed until the hook returns
+	hook := func(_, to fsm.State, _ fsm.Event) {
+		if to.Description() != unlocked {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := m.Fire(ctx, newEvent(push))
+		fired <- err
+	}
+
+	table, err := fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Transition(locked, coin, nil, unlocked).
+		Transition(unlocked, push, nil, locked).
+		Build()
+	require.NoError(t, err)
+
+	m, _, err = fsm.NewFromTable(uuid.New(), "turnstile", table, fsm.WithAfterTransition(hook))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(ctx, make(chan fsm.Event), func() error { return nil })
+	}()
+
+	t.Run("Fire from a hook should return once its context is done", func(t *testing.T) {
+		state, err := m.Fire(context.Background(), newEvent(coin))
+		require.NoError(t, err)
+		assert.Equal(t, unlocked, state.Description())
+		assert.ErrorIs(t, <-fired, context.DeadlineExceeded)
+	})
+
+	t.Run("The machine should process events once the hook has returned", func(t *testing.T) {
+		state, err := m.Fire(context.Background(), newEvent(push))
+		require.NoError(t, err)
+		assert.Equal(t, locked, state.Description())
+	})
+
+	cancel()
+	require.NoError(t, <-done)
+}
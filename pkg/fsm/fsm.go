@@ -1,6 +1,7 @@
 package fsm
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
@@ -22,9 +23,28 @@ func TimestampToString(t int64) string {
 	return TimestampToTime(t).Format(TimestampFormat)
 }
 
+// ErrEventNotHandled can be used with errors.Is to check whether an error was caused by
+// an event that the state machine did not expect
+var ErrEventNotHandled = errors.New("event not handled")
+
+// UnexpectedEventError is returned when a state or transition table receives an event
+// it does not handle
+type UnexpectedEventError struct {
+	Event Event
+}
+
+func (e UnexpectedEventError) Error() string {
+	return fmt.Sprintf("an unexpected event %s was received from %s", e.Event.Name(), e.Event.Source())
+}
+
+// Is reports whether the target is ErrEventNotHandled
+func (e UnexpectedEventError) Is(target error) bool {
+	return target == ErrEventNotHandled
+}
+
 // ErrUnexpectedEvent returns an error describing the event received
 func ErrUnexpectedEvent(event Event) error {
-	return fmt.Errorf("an unexpected event %s was received from %s", event.Name(), event.Source())
+	return UnexpectedEventError{Event: event}
 }
 
 func ErrUnexpectedState(state State) error {
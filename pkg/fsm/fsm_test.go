@@ -0,0 +1,136 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	"gitlab.com/gobl/gobl/pkg/config"
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+const (
+	coin = "coin"
+	push = "push"
+	fail = "fail"
+
+	locked   = "locked"
+	unlocked = "unlocked"
+	broken   = "broken"
+)
+
+var errFailed = errors.New("failed to process event")
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "fsm")
+	if err != nil {
+		panic(err)
+	}
+
+	viper.Set(config.LogFilePathKey, filepath.Join(dir, "fsm.log"))
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type testEvent struct {
+	id        uuid.UUID
+	name      string
+	timestamp int64
+}
+
+func newEvent(name string) testEvent {
+	return testEvent{id: uuid.New(), name: name, timestamp: time.Now().UnixNano()}
+}
+
+func (e testEvent) ID() uuid.UUID    { return e.id }
+func (e testEvent) Source() string   { return "test" }
+func (e testEvent) Name() string     { return e.name }
+func (e testEvent) Timestamp() int64 { return e.timestamp }
+
+type testState struct {
+	id       uuid.UUID
+	name     string
+	Coins    int
+	Received []string
+}
+
+func newState(name string) *testState {
+	return &testState{id: uuid.New(), name: name}
+}
+
+// stateFn returns a NextFn that creates a new named state, carrying the coins across
+func stateFn(name string) fsm.NextFn {
+	return func(previous fsm.State) fsm.State {
+		s := newState(name)
+		if p, ok := previous.(*testState); ok {
+			s.Coins = p.Coins
+		}
+		return s
+	}
+}
+
+func (s *testState) ID() uuid.UUID       { return s.id }
+func (s *testState) SetID(id uuid.UUID)  { s.id = id }
+func (s *testState) Description() string { return s.name }
+
+func (s *testState) Execute(e fsm.Event) error {
+	if e.Name() == fail {
+		return errFailed
+	}
+
+	if e.Name() == coin {
+		s.Coins++
+	}
+
+	s.Received = append(s.Received, e.Name())
+	return nil
+}
+
+func (s *testState) Next() fsm.State                             { return s }
+func (s *testState) WithTransitions(...fsm.Transition) fsm.State { return s }
+
+func (s *testState) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func (s *testState) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, s)
+}
+
+func (s *testState) coinCount() int {
+	return s.Coins
+}
+
+type coinCounter interface {
+	coinCount() int
+}
+
+func hasCoin(s fsm.State) bool {
+	c, ok := s.(coinCounter)
+	return ok && c.coinCount() > 0
+}
+
+func hasTwoCoins(s fsm.State) bool {
+	c, ok := s.(coinCounter)
+	return ok && c.coinCount() > 1
+}
+
+// turnstile returns a builder for a simple turnstile that breaks after two coins
+func turnstile() *fsm.Builder {
+	return fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Final(broken, stateFn(broken)).
+		Transition(locked, coin, hasTwoCoins, broken).
+		Transition(locked, coin, hasCoin, unlocked).
+		Transition(locked, fail, nil, locked).
+		Transition(unlocked, push, nil, locked)
+}
@@ -0,0 +1,151 @@
+package fsm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GraphFormat is the text format a transition table can be rendered in
+type GraphFormat int
+
+const (
+	// DOT renders the transition table as a Graphviz digraph
+	DOT GraphFormat = iota
+	// Mermaid renders the transition table as a Mermaid state diagram
+	Mermaid
+)
+
+const graphFileMode = 0o644
+
+// Extension returns the file extension that is normally used for the format
+func (f GraphFormat) Extension() string {
+	switch f {
+	case Mermaid:
+		return ".mmd"
+	default:
+		return ".dot"
+	}
+}
+
+// DOT returns the transition table as a Graphviz digraph with the given name
+func (t *Table) DOT(name string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(name))
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\t__start [shape=point];\n")
+
+	for _, state := range t.order {
+		shape := "circle"
+		if t.finals[state] {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(&b, "\t%s [shape=%s];\n", dotQuote(state), shape)
+	}
+
+	if t.initial != "" {
+		fmt.Fprintf(&b, "\t__start -> %s;\n", dotQuote(t.initial))
+	}
+
+	for _, tr := range t.transitions {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", dotQuote(tr.From), dotQuote(tr.To), dotQuote(transitionLabel(tr)))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid returns the transition table as a Mermaid state diagram. The states are given the identifiers
+// s0, s1, ... in the order they were registered, and are labelled with their names, so that states whose
+// names Mermaid would not accept as identifiers are still drawn separately.
+func (t *Table) Mermaid() string {
+	var b strings.Builder
+
+	ids := make(map[string]string, len(t.order))
+
+	b.WriteString("stateDiagram-v2\n")
+
+	for i, state := range t.order {
+		ids[state] = fmt.Sprintf("s%d", i)
+		fmt.Fprintf(&b, "    %s : %s\n", ids[state], mermaidLabel(state))
+	}
+
+	if t.initial != "" {
+		fmt.Fprintf(&b, "    [*] --> %s\n", ids[t.initial])
+	}
+
+	for _, tr := range t.transitions {
+		fmt.Fprintf(&b, "    %s --> %s : %s\n", ids[tr.From], ids[tr.To], mermaidLabel(transitionLabel(tr)))
+	}
+
+	for _, state := range t.order {
+		if t.finals[state] {
+			fmt.Fprintf(&b, "    %s --> [*]\n", ids[state])
+		}
+	}
+
+	return b.String()
+}
+
+// WriteGraph writes the transition table to w in the given format
+func WriteGraph(w io.Writer, name string, t *Table, format GraphFormat) error {
+	var graph string
+
+	switch format {
+	case Mermaid:
+		graph = t.Mermaid()
+	default:
+		graph = t.DOT(name)
+	}
+
+	_, err := io.WriteString(w, graph)
+
+	return err
+}
+
+// WriteGraphFiles writes the transition table as both a DOT and a Mermaid file to the given
+// directory, using the name for the file names, e.g. turnstile.dot and turnstile.mmd.
+// It is intended to be called from tests so diagrams are regenerated alongside the code.
+func WriteGraphFiles(dir, name string, t *Table) error {
+	for _, format := range []GraphFormat{DOT, Mermaid} {
+		path := filepath.Join(dir, name+format.Extension())
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, graphFileMode)
+		if err != nil {
+			return fmt.Errorf("creating graph file %s: %w", path, err)
+		}
+
+		if err := WriteGraph(f, name, t, format); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("writing graph file %s: %w", path, err)
+		}
+
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("closing graph file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// transitionLabel returns the event name of the transition followed by the guard name if it has one
+func transitionLabel(tr TableTransition) string {
+	if tr.Guard == nil {
+		return tr.Event
+	}
+
+	return fmt.Sprintf("%s [%s]", tr.Event, GuardName(tr.Guard))
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// mermaidLabel removes characters that would end a Mermaid state or transition label early
+func mermaidLabel(s string) string {
+	return strings.NewReplacer(":", " ", ";", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,84 @@
+package fsm_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+func TestTable_DOT(t *testing.T) {
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	want := `digraph "turnstile" {
+	rankdir=LR;
+	__start [shape=point];
+	"locked" [shape=circle];
+	"unlocked" [shape=circle];
+	"broken" [shape=doublecircle];
+	__start -> "locked";
+	"locked" -> "broken" [label="coin [fsm_test.hasTwoCoins]"];
+	"locked" -> "unlocked" [label="coin [fsm_test.hasCoin]"];
+	"locked" -> "locked" [label="fail"];
+	"unlocked" -> "locked" [label="push"];
+}
+`
+	assert.Equal(t, want, table.DOT("turnstile"))
+}
+
+func TestTable_Mermaid(t *testing.T) {
+	table, err := fsm.NewBuilder().
+		Initial("Is Locked", stateFn(locked)).
+		Final(unlocked, stateFn(unlocked)).
+		Transition("Is Locked", coin, hasCoin, unlocked).
+		Build()
+	require.NoError(t, err)
+
+	want := `stateDiagram-v2
+    s0 : Is Locked
+    s1 : unlocked
+    [*] --> s0
+    s0 --> s1 : coin [fsm_test.hasCoin]
+    s1 --> [*]
+`
+	assert.Equal(t, want, table.Mermaid())
+
+	t.Run("Mermaid should draw states whose names only differ by punctuation separately", func(t *testing.T) {
+		table, err := fsm.NewBuilder().
+			Initial("a-b", stateFn("a-b")).
+			Final("a_b", stateFn("a_b")).
+			Transition("a-b", coin, nil, "a_b").
+			Build()
+		require.NoError(t, err)
+
+		want := `stateDiagram-v2
+    s0 : a-b
+    s1 : a_b
+    [*] --> s0
+    s0 --> s1 : coin
+    s1 --> [*]
+`
+		assert.Equal(t, want, table.Mermaid())
+	})
+}
+
+func TestWriteGraphFiles(t *testing.T) {
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, fsm.WriteGraphFiles(dir, "turnstile", table))
+
+	dot, err := os.ReadFile(filepath.Join(dir, "turnstile.dot"))
+	require.NoError(t, err)
+	assert.Equal(t, table.DOT("turnstile"), string(dot))
+
+	mmd, err := os.ReadFile(filepath.Join(dir, "turnstile.mmd"))
+	require.NoError(t, err)
+	assert.Equal(t, table.Mermaid(), string(mmd))
+}
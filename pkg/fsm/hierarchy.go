@@ -0,0 +1,318 @@
+package fsm
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Composite is a state that contains child states, e.g. an Active state containing Idle and Busy.
+// Events are executed by the active child first, and bubble up to the composite's handler when the
+// child does not handle them. After each event the active child moves on using its own transitions,
+// while the transitions added to the composite are shared by all of its children.
+// When the composite is entered, its entry actions run before those of the active child, and
+// when it is exited, the child's exit actions run before those of the composite.
+type Composite struct {
+	id          uuid.UUID
+	description string
+	child       State
+	handler     func(Event) error
+	entry       []ActionFn
+	exit        []ActionFn
+	transitions []Transition
+	completed   bool
+}
+
+// NewComposite creates a composite state that starts with the given child state active
+func NewComposite(id uuid.UUID, description string, initial State) *Composite {
+	return &Composite{
+		id:          id,
+		description: description,
+		child:       initial,
+	}
+}
+
+// WithHandler sets the function that handles events that the active child does not handle
+func (c *Composite) WithHandler(fn func(Event) error) *Composite {
+	c.handler = fn
+	return c
+}
+
+// WithEntry adds actions that are run when the composite state is entered
+func (c *Composite) WithEntry(fns ...ActionFn) *Composite {
+	c.entry = append(c.entry, fns...)
+	return c
+}
+
+// WithExit adds actions that are run when the composite state is exited
+func (c *Composite) WithExit(fns ...ActionFn) *Composite {
+	c.exit = append(c.exit, fns...)
+	return c
+}
+
+// ID returns the unique id of the composite state
+func (c *Composite) ID() uuid.UUID {
+	return c.id
+}
+
+// Description returns the description of the composite state
+func (c *Composite) Description() string {
+	return c.description
+}
+
+// Child returns the active child state
+func (c *Composite) Child() State {
+	return c.child
+}
+
+// Completed returns true once the active child has no next state
+func (c *Composite) Completed() bool {
+	return c.completed
+}
+
+// Execute passes the event to the active child, bubbling it up to the composite's handler
+// if the child does not handle it, then moves the child on to its next state
+func (c *Composite) Execute(event Event) error {
+	return c.process(event, false)
+}
+
+// Replay is the same as Execute except that the active child is replayed and no entry or
+// exit actions are run when the child changes
+func (c *Composite) Replay(event Event) error {
+	return c.process(event, true)
+}
+
+func (c *Composite) process(event Event, replay bool) error {
+	err := execute(c.child, event, replay)
+
+	if errors.Is(err, ErrEventNotHandled) {
+		if c.handler == nil {
+			return err
+		}
+		return c.handler(event)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.child, c.completed, err = advance(c.child, event, replay)
+
+	return err
+}
+
+// Next evaluates the transitions of the composite state
+func (c *Composite) Next() State {
+	return Next(c, c.transitions...)
+}
+
+// WithTransitions sets the transitions out of the composite state
+func (c *Composite) WithTransitions(transitions ...Transition) State {
+	c.transitions = append(c.transitions, transitions...)
+	return c
+}
+
+// OnEnter runs the entry actions of the composite followed by those of the active child
+func (c *Composite) OnEnter(event Event) error {
+	if err := runActions(c.entry, event); err != nil {
+		return err
+	}
+
+	return enter(c.child, event)
+}
+
+// OnExit runs the exit actions of the active child followed by those of the composite
+func (c *Composite) OnExit(event Event) error {
+	if err := exit(c.child, event); err != nil {
+		return err
+	}
+
+	return runActions(c.exit, event)
+}
+
+// Parallel is a state made up of orthogonal regions that are active at the same time.
+// Every event is passed to each region and each region moves on independently. An event
+// is only unhandled if none of the regions handle it, so it can bubble up to a parent composite.
+type Parallel struct {
+	id          uuid.UUID
+	description string
+	regions     []State
+	completed   []bool
+	entry       []ActionFn
+	exit        []ActionFn
+	transitions []Transition
+}
+
+// NewParallel creates a parallel state with each region starting in the given state
+func NewParallel(id uuid.UUID, description string, regions ...State) *Parallel {
+	return &Parallel{
+		id:          id,
+		description: description,
+		regions:     regions,
+		completed:   make([]bool, len(regions)),
+	}
+}
+
+// WithEntry adds actions that are run when the parallel state is entered
+func (p *Parallel) WithEntry(fns ...ActionFn) *Parallel {
+	p.entry = append(p.entry, fns...)
+	return p
+}
+
+// WithExit adds actions that are run when the parallel state is exited
+func (p *Parallel) WithExit(fns ...ActionFn) *Parallel {
+	p.exit = append(p.exit, fns...)
+	return p
+}
+
+// ID returns the unique id of the parallel state
+func (p *Parallel) ID() uuid.UUID {
+	return p.id
+}
+
+// Description returns the description of the parallel state
+func (p *Parallel) Description() string {
+	return p.description
+}
+
+// Regions returns the active state of each region
+func (p *Parallel) Regions() []State {
+	regions := make([]State, len(p.regions))
+	copy(regions, p.regions)
+
+	return regions
+}
+
+// Completed returns true once none of the regions have a next state
+func (p *Parallel) Completed() bool {
+	for _, done := range p.completed {
+		if !done {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Execute passes the event to each region and moves the regions on to their next states
+func (p *Parallel) Execute(event Event) error {
+	return p.process(event, false)
+}
+
+// Replay is the same as Execute except that the regions are replayed and no entry or
+// exit actions are run when a region changes state
+func (p *Parallel) Replay(event Event) error {
+	return p.process(event, true)
+}
+
+func (p *Parallel) process(event Event, replay bool) error {
+	var errs []error
+
+	handled := false
+
+	for i, region := range p.regions {
+		err := execute(region, event, replay)
+		if errors.Is(err, ErrEventNotHandled) {
+			continue
+		}
+
+		handled = true
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		p.regions[i], p.completed[i], err = advance(region, event, replay)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if !handled {
+		return ErrUnexpectedEvent(event)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Next evaluates the transitions of the parallel state
+func (p *Parallel) Next() State {
+	return Next(p, p.transitions...)
+}
+
+// WithTransitions sets the transitions out of the parallel state
+func (p *Parallel) WithTransitions(transitions ...Transition) State {
+	p.transitions = append(p.transitions, transitions...)
+	return p
+}
+
+// OnEnter runs the entry actions of the parallel state followed by those of each region
+func (p *Parallel) OnEnter(event Event) error {
+	if err := runActions(p.entry, event); err != nil {
+		return err
+	}
+
+	for _, region := range p.regions {
+		if err := enter(region, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OnExit runs the exit actions of each region, in reverse order, followed by those of the parallel state
+func (p *Parallel) OnExit(event Event) error {
+	for i := len(p.regions) - 1; i >= 0; i-- {
+		if err := exit(p.regions[i], event); err != nil {
+			return err
+		}
+	}
+
+	return runActions(p.exit, event)
+}
+
+// execute passes the event to the state, replaying it if requested and the state supports it
+func execute(state State, event Event, replay bool) error {
+	if replayer, ok := state.(Replayer); ok && replay {
+		return replayer.Replay(event)
+	}
+
+	return state.Execute(event)
+}
+
+// advance moves a child state on to its next state, running the exit and entry actions unless
+// replaying. It returns the active state and whether the child has completed.
+func advance(current State, event Event, replay bool) (State, bool, error) {
+	next := current.Next()
+	if next == nil {
+		return current, true, nil
+	}
+
+	if !changed(current, next) {
+		return current, false, nil
+	}
+
+	if !replay {
+		if err := exit(current, event); err != nil {
+			return current, false, err
+		}
+
+		if err := enter(next, event); err != nil {
+			return next, false, err
+		}
+	}
+
+	return next, false, nil
+}
+
+func runActions(fns []ActionFn, event Event) error {
+	for _, fn := range fns {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+// node is a state that handles a single event, moves to the state created by next once it has,
+// and records its entry and exit actions in the log
+type node struct {
+	id          uuid.UUID
+	name        string
+	handles     string
+	handled     bool
+	log         *[]string
+	transitions []fsm.Transition
+}
+
+func newNode(name, handles string, log *[]string) *node {
+	return &node{id: uuid.New(), name: name, handles: handles, log: log}
+}
+
+func (n *node) ID() uuid.UUID       { return n.id }
+func (n *node) Description() string { return n.name }
+
+func (n *node) Execute(e fsm.Event) error {
+	if e.Name() != n.handles {
+		return fsm.ErrUnexpectedEvent(e)
+	}
+	n.handled = true
+	return nil
+}
+
+func (n *node) Next() fsm.State { return fsm.Next(n, n.transitions...) }
+
+func (n *node) WithTransitions(transitions ...fsm.Transition) fsm.State {
+	n.transitions = append(n.transitions, transitions...)
+	return n
+}
+
+func (n *node) OnEnter(fsm.Event) error {
+	*n.log = append(*n.log, "enter "+n.name)
+	return nil
+}
+
+func (n *node) OnExit(fsm.Event) error {
+	*n.log = append(*n.log, "exit "+n.name)
+	return nil
+}
+
+func handled(s fsm.State) bool {
+	n, ok := s.(*node)
+	return ok && n.handled
+}
+
+func TestComposite(t *testing.T) {
+	var log []string
+
+	var idle, busy func(fsm.State) fsm.State
+	idle = func(fsm.State) fsm.State {
+		return newNode("idle", "work", &log).WithTransitions(fsm.Transition{Checks: []fsm.CheckFn{handled}, Next: busy})
+	}
+	busy = func(fsm.State) fsm.State {
+		return newNode("busy", "done", &log).WithTransitions(fsm.Transition{Checks: []fsm.CheckFn{handled}, Next: idle})
+	}
+
+	paused := false
+	active := fsm.NewComposite(uuid.New(), "active", idle(nil)).
+		WithHandler(func(e fsm.Event) error {
+			if e.Name() != "pause" {
+				return fsm.ErrUnexpectedEvent(e)
+			}
+			paused = true
+			return nil
+		}).
+		WithEntry(func(fsm.Event) error {
+			log = append(log, "enter active")
+			return nil
+		}).
+		WithExit(func(fsm.Event) error {
+			log = append(log, "exit active")
+			return nil
+		})
+	active.WithTransitions(fsm.Transition{
+		Checks: []fsm.CheckFn{func(fsm.State) bool { return paused }},
+		Next: func(fsm.State) fsm.State {
+			return newNode("paused", "resume", &log)
+		},
+	})
+
+	m, errCh := fsm.New(uuid.New(), "worker", active)
+
+	events := make(chan fsm.Event)
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(context.Background(), events, func() error { return nil })
+	}()
+
+	events <- newEvent("work")
+	events <- newEvent("unknown")
+	assert.ErrorIs(t, <-errCh, fsm.ErrEventNotHandled)
+	events <- newEvent("pause")
+	close(events)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, "paused", m.CurrentDescription())
+	assert.Equal(t, []string{
+		"enter active",
+		"enter idle",
+		"exit idle",
+		"enter busy",
+		"exit busy",
+		"exit active",
+		"enter paused",
+	}, log)
+}
+
+func TestParallel(t *testing.T) {
+	var log []string
+
+	left := newNode("left", "a", &log).WithTransitions(fsm.Transition{
+		Checks: []fsm.CheckFn{handled},
+		Next:   func(fsm.State) fsm.State { return newNode("left done", "", &log) },
+	})
+	right := newNode("right", "b", &log)
+
+	p := fsm.NewParallel(uuid.New(), "both", left, right)
+	require.NoError(t, p.OnEnter(nil))
+
+	t.Run("Execute should advance the regions that handle the event", func(t *testing.T) {
+		require.NoError(t, p.Execute(newEvent("a")))
+		regions := p.Regions()
+		assert.Equal(t, "left done", regions[0].Description())
+		assert.Equal(t, "right", regions[1].Description())
+	})
+
+	t.Run("Execute should return an unhandled error if no region handles the event", func(t *testing.T) {
+		assert.ErrorIs(t, p.Execute(newEvent("c")), fsm.ErrEventNotHandled)
+	})
+
+	t.Run("Completed should be false while any region is still active", func(t *testing.T) {
+		assert.False(t, p.Completed())
+	})
+
+	t.Run("OnExit should exit the regions in reverse order", func(t *testing.T) {
+		require.NoError(t, p.OnExit(nil))
+		assert.Equal(t, []string{
+			"enter left",
+			"enter right",
+			"exit left",
+			"enter left done",
+			"exit right",
+			"exit left done",
+		}, log)
+	})
+}
@@ -0,0 +1,45 @@
+package fsm
+
+import (
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/logger"
+)
+
+// TransitionHookFn is called when the state machine moves from one state to another because of the event
+type TransitionHookFn func(from, to State, event Event)
+
+// ErrorHookFn is called when the state machine encounters an error while handling the event.
+// The event is nil if the error did not happen while handling an event, e.g. entering the initial state.
+type ErrorHookFn func(current State, event Event, err error)
+
+func (m *Machine) beforeTransition(next State, event Event) {
+	for _, fn := range m.options.beforeTransition {
+		fn(m.current, next, event)
+	}
+}
+
+func (m *Machine) afterTransition(previous State, event Event) {
+	for _, fn := range m.options.afterTransition {
+		fn(previous, m.current, event)
+	}
+}
+
+// onError calls the error hooks
+func (m *Machine) onError(event Event, err error) {
+	for _, fn := range m.options.onError {
+		fn(m.current, event, err)
+	}
+}
+
+// fail calls the error hooks and publishes the error on the error channel. If the error channel's
+// buffer is full the error is logged and dropped rather than blocking the state machine.
+func (m *Machine) fail(event Event, err error) {
+	m.onError(event, err)
+
+	select {
+	case m.errCh <- err:
+	default:
+		logger.Logger().Warn("State machine error channel is full, dropping error", zap.Error(err))
+	}
+}
@@ -0,0 +1,51 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+func TestMachine_Hooks(t *testing.T) {
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	var calls []string
+
+	m, errCh, err := fsm.NewFromTable(uuid.New(), "turnstile", table,
+		fsm.WithBeforeTransition(func(from, to fsm.State, e fsm.Event) {
+			calls = append(calls, "before "+from.Description()+" -> "+to.Description()+" on "+e.Name())
+		}),
+		fsm.WithAfterTransition(func(from, to fsm.State, e fsm.Event) {
+			calls = append(calls, "after "+from.Description()+" -> "+to.Description()+" on "+e.Name())
+		}),
+		fsm.WithErrorHook(func(current fsm.State, e fsm.Event, err error) {
+			calls = append(calls, "error in "+current.Description()+" on "+e.Name())
+		}),
+	)
+	require.NoError(t, err)
+
+	events := make(chan fsm.Event)
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(context.Background(), events, func() error { return nil })
+	}()
+
+	events <- newEvent(coin)
+	events <- newEvent(coin)
+	assert.ErrorIs(t, <-errCh, fsm.ErrEventNotHandled)
+	close(events)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{
+		"before locked -> unlocked on coin",
+		"after locked -> unlocked on coin",
+		"error in unlocked on coin",
+	}, calls)
+}
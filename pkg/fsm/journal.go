@@ -0,0 +1,182 @@
+package fsm
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Record is a journalled event
+type Record struct {
+	// MachineID is the unique id of the state machine that received the event
+	MachineID uuid.UUID `json:"machineId"`
+	// ID is the unique id of the event
+	ID uuid.UUID `json:"id"`
+	// Source is where the event came from
+	Source string `json:"source"`
+	// Name is the name of the event
+	Name string `json:"name"`
+	// Timestamp is the time of the event as nanoseconds past epoch
+	Timestamp int64 `json:"timestamp"`
+	// Payload is the serialised event, if the event implements encoding.BinaryMarshaler
+	Payload []byte `json:"payload"`
+}
+
+// Journal is an append only log of the events received by state machines
+type Journal interface {
+	// Append adds the record to the end of the machine's journal
+	Append(ctx context.Context, record Record) error
+	// Records returns the machine's journal in the order the records were appended
+	Records(ctx context.Context, machineID uuid.UUID) ([]Record, error)
+}
+
+// EventDecoder recreates an event from a journal record
+type EventDecoder func(Record) (Event, error)
+
+// Replayer can be implemented by states that have side effects when they execute an event.
+// When a state machine replays its journal, Replay is called instead of Execute so the state
+// can update its data without repeating the side effects.
+type Replayer interface {
+	Replay(Event) error
+}
+
+// NewRecord creates a journal record for the event received by the machine
+func NewRecord(machineID uuid.UUID, event Event) (Record, error) {
+	r := Record{
+		MachineID: machineID,
+		ID:        event.ID(),
+		Source:    event.Source(),
+		Name:      event.Name(),
+		Timestamp: event.Timestamp(),
+	}
+
+	if marshaler, ok := event.(encoding.BinaryMarshaler); ok {
+		payload, err := marshaler.MarshalBinary()
+		if err != nil {
+			return r, fmt.Errorf("serialising event %s: %w", event.Name(), err)
+		}
+		r.Payload = payload
+	}
+
+	return r, nil
+}
+
+// journal appends the event to the configured journal, if there is one
+func (m *Machine) journal(ctx context.Context, event Event) error {
+	if m.options.journal == nil {
+		return nil
+	}
+
+	r, err := NewRecord(m.id, event)
+	if err != nil {
+		return err
+	}
+
+	if err := m.options.journal.Append(ctx, r); err != nil {
+		return fmt.Errorf("appending event to journal: %w", err)
+	}
+
+	return nil
+}
+
+// Replay rebuilds the state of the machine by re-feeding the events recorded in the journal
+// for the machine's id. Records are replayed in the order they were appended, which is the order
+// the machine processed them, and duplicate event ids are skipped. Event timestamps are not used
+// as they come from the producers and can be skewed or tied.
+// Events that fail when replayed are skipped, as they failed when they were first processed and
+// did not change the state then either. Like the error channel, the failures are not reported.
+// Side effects are suppressed while replaying: nothing is journalled or snapshotted, errors are
+// not published and states that implement Replayer have Replay called instead of Execute.
+// Replay must be called before Run.
+func (m *Machine) Replay(ctx context.Context, journal Journal, decode EventDecoder) error {
+	if m.hasRun {
+		return ErrAlreadyRun
+	}
+
+	records, err := journal.Records(ctx, m.id)
+	if err != nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+
+	m.processing.Lock()
+	defer m.processing.Unlock()
+
+	m.replaying = true
+	defer func() {
+		m.replaying = false
+	}()
+
+	seen := make(map[uuid.UUID]bool, len(records))
+
+	for _, r := range records {
+		if seen[r.ID] {
+			continue
+		}
+		seen[r.ID] = true
+
+		event, err := decode(r)
+		if err != nil {
+			return fmt.Errorf("decoding journal record %s: %w", r.ID, err)
+		}
+
+		name, next, err := m.step(event)
+		if err != nil {
+			continue
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		m.setCurrent(name, next)
+
+		if m.table != nil && m.table.IsFinal(name) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// execute passes the event to the state, using Replay instead of Execute when the machine
+// is replaying its journal
+func (m *Machine) execute(state State, event Event) error {
+	return execute(state, event, m.replaying)
+}
+
+// MemoryJournal is an in-memory Journal, useful for testing
+type MemoryJournal struct {
+	mu      sync.RWMutex
+	records map[uuid.UUID][]Record
+}
+
+// NewMemoryJournal creates an empty in-memory Journal
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{
+		records: make(map[uuid.UUID][]Record),
+	}
+}
+
+// Append adds the record to the machine's journal
+func (j *MemoryJournal) Append(_ context.Context, record Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.records[record.MachineID] = append(j.records[record.MachineID], record)
+
+	return nil
+}
+
+// Records returns a copy of the machine's journal
+func (j *MemoryJournal) Records(_ context.Context, machineID uuid.UUID) ([]Record, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	records := make([]Record, len(j.records[machineID]))
+	copy(records, j.records[machineID])
+
+	return records, nil
+}
@@ -0,0 +1,95 @@
+package fsm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// FileJournal is a Journal that appends each machine's records to a JSON lines file in a directory
+type FileJournal struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileJournal creates a Journal that writes to the given directory, creating the
+// directory if it does not exist
+func NewFileJournal(dir string) (*FileJournal, error) {
+	if err := os.MkdirAll(dir, snapshotDirMode); err != nil {
+		return nil, fmt.Errorf("creating journal directory: %w", err)
+	}
+
+	return &FileJournal{dir: dir}, nil
+}
+
+// Append adds the record to the end of the machine's journal file
+func (j *FileJournal) Append(_ context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding journal record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path(record.MachineID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, snapshotFileMode)
+	if err != nil {
+		return fmt.Errorf("opening journal file: %w", err)
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing journal file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Records reads the machine's journal file
+func (j *FileJournal) Records(_ context.Context, machineID uuid.UUID) ([]Record, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	records := make([]Record, 0)
+
+	f, err := os.Open(j.path(machineID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("opening journal file: %w", err)
+	}
+
+	defer f.Close()
+
+	// a decoder is used rather than a line scanner so records are not limited in size
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var r Record
+
+		err := decoder.Decode(&r)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("decoding journal record: %w", err)
+		}
+
+		records = append(records, r)
+	}
+}
+
+func (j *FileJournal) path(machineID uuid.UUID) string {
+	return filepath.Join(j.dir, machineID.String()+".jsonl")
+}
@@ -0,0 +1,166 @@
+package fsm_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+// replayingState counts the events it has replayed so tests can check side effects are suppressed
+type replayingState struct {
+	*testState
+	executed int
+	replayed int
+}
+
+func (s *replayingState) Execute(e fsm.Event) error {
+	s.executed++
+	return s.testState.Execute(e)
+}
+
+func (s *replayingState) Replay(e fsm.Event) error {
+	s.replayed++
+	return s.testState.Execute(e)
+}
+
+func decodeEvent(r fsm.Record) (fsm.Event, error) {
+	return testEvent{id: r.ID, name: r.Name, timestamp: r.Timestamp}, nil
+}
+
+func TestMachine_Replay(t *testing.T) {
+	fileJournal, err := fsm.NewFileJournal(t.TempDir())
+	require.NoError(t, err)
+
+	journals := map[string]fsm.Journal{
+		"memory": fsm.NewMemoryJournal(),
+		"file":   fileJournal,
+	}
+
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	for name, journal := range journals {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			id := uuid.New()
+
+			m, errCh, err := fsm.NewFromTable(id, "turnstile", table, fsm.WithJournal(journal))
+			require.NoError(t, err)
+
+			events := make(chan fsm.Event)
+			done := make(chan error)
+
+			go func() {
+				done <- m.Run(ctx, events, func() error { return nil })
+			}()
+
+			first := newEvent(coin)
+			events <- first
+			events <- newEvent(push)
+			events <- newEvent(fail)
+			require.ErrorIs(t, <-errCh, errFailed)
+			close(events)
+			require.NoError(t, <-done)
+
+			// journal the first event again to make sure duplicates are ignored
+			record, err := fsm.NewRecord(id, first)
+			require.NoError(t, err)
+			require.NoError(t, journal.Append(ctx, record))
+
+			records, err := journal.Records(ctx, id)
+			require.NoError(t, err)
+			assert.Len(t, records, 4)
+
+			var states []*replayingState
+			replayTable, err := fsm.NewBuilder().
+				Initial(locked, replayingFn(&states, locked)).
+				State(unlocked, replayingFn(&states, unlocked)).
+				Transition(locked, coin, hasCoin, unlocked).
+				Transition(locked, fail, nil, locked).
+				Transition(unlocked, push, nil, locked).
+				Build()
+			require.NoError(t, err)
+
+			replayed, _, err := fsm.NewFromTable(id, "turnstile", replayTable)
+			require.NoError(t, err)
+			require.NoError(t, replayed.Replay(ctx, journal, decodeEvent))
+			assert.Equal(t, locked, replayed.CurrentName())
+
+			executed := 0
+			replays := 0
+			for _, s := range states {
+				executed += s.executed
+				replays += s.replayed
+			}
+			assert.Equal(t, 0, executed)
+			assert.Len(t, states, 3)
+			assert.Equal(t, 3, replays)
+		})
+	}
+}
+
+func replayingFn(states *[]*replayingState, name string) fsm.NextFn {
+	return func(previous fsm.State) fsm.State {
+		s := &replayingState{testState: newState(name)}
+		if p, ok := previous.(*replayingState); ok {
+			s.Coins = p.Coins
+		}
+		*states = append(*states, s)
+		return s
+	}
+}
+
+func TestMachine_Replay_Order(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	journal := fsm.NewMemoryJournal()
+
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	// the push was produced by a clock that is behind, so its timestamp is before the coin's
+	coinEvent := newEvent(coin)
+	pushEvent := testEvent{id: uuid.New(), name: push, timestamp: coinEvent.timestamp - int64(time.Minute)}
+
+	for _, e := range []fsm.Event{coinEvent, pushEvent} {
+		record, err := fsm.NewRecord(id, e)
+		require.NoError(t, err)
+		require.NoError(t, journal.Append(ctx, record))
+	}
+
+	t.Run("Replay should replay the records in the order they were appended", func(t *testing.T) {
+		m, _, err := fsm.NewFromTable(id, "turnstile", table)
+		require.NoError(t, err)
+		require.NoError(t, m.Replay(ctx, journal, decodeEvent))
+		assert.Equal(t, locked, m.CurrentName())
+	})
+}
+
+func TestFileJournal_Records(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	journal, err := fsm.NewFileJournal(t.TempDir())
+	require.NoError(t, err)
+
+	t.Run("Records should read records longer than a line buffer", func(t *testing.T) {
+		record, err := fsm.NewRecord(id, newEvent(coin))
+		require.NoError(t, err)
+		record.Payload = bytes.Repeat([]byte("a"), 128*1024)
+
+		require.NoError(t, journal.Append(ctx, record))
+		require.NoError(t, journal.Append(ctx, record))
+
+		records, err := journal.Records(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, record.Payload, records[1].Payload)
+	})
+}
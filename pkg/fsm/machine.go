@@ -3,6 +3,9 @@ package fsm
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -24,31 +27,95 @@ type FSM interface {
 	Run(context.Context, <-chan Event, func() error) error
 }
 
+// Firer is implemented by state machines that can process an event synchronously
+type Firer interface {
+	// Fire passes an event to the running state machine and returns the resulting state
+	Fire(context.Context, Event) (State, error)
+}
+
+var (
+	_ FSM   = (*Machine)(nil)
+	_ Firer = (*Machine)(nil)
+)
+
 type Machine struct {
-	id      uuid.UUID
-	name    string
-	current State
-	errCh   chan error
-	hasRun  bool
+	mu sync.RWMutex
+	// processing is held while an event is processed, so the current state can be read consistently
+	// from outside the run loop
+	processing sync.Mutex
+	id         uuid.UUID
+	name       string
+	current    State
+	errCh      chan error
+	hasRun     bool
+	// table and currentName are only set when the machine is created from a transition table
+	table       *Table
+	currentName string
+	options     options
+	replaying   bool
+	// timers are the timers armed for the current state, generation is incremented each time they are
+	// cancelled so timers that fire late can be ignored
+	timers     []Timer
+	generation uint64
+	timerCh    chan timerFired
+	fireCh     chan fireRequest
+	stopCh     chan struct{}
+	// enteredAt is the time the machine entered its current state
+	enteredAt time.Time
 }
 
+var (
+	// ErrAlreadyRun is returned when Run or Replay is called on a state machine that has already been run
+	ErrAlreadyRun = errors.New("state machine has already been run and cannot be re-run, you need to create a new state machine")
+	// ErrMachineStopped is returned when an event is fired at a state machine that has stopped running
+	ErrMachineStopped = errors.New("state machine has stopped")
+)
+
 // New creates a state machine with the given initial state
 // and a channel where any errors generated by the state machine will
-// be published
+// be published. The error channel is buffered, see WithErrorBuffer.
 //
 //nolint:gocritic
-func New(id uuid.UUID, name string, init State) (*Machine, chan error) {
+func New(id uuid.UUID, name string, init State, opts ...Option) (*Machine, chan error) {
+	def := defaultOptions()
+	for _, o := range opts {
+		o(&def)
+	}
+
 	m := new(Machine)
 	m.id = id
 	m.name = name
 	m.current = init
 	m.hasRun = false
-	errCh := make(chan error)
+	m.options = def
+	m.timerCh = make(chan timerFired)
+	m.fireCh = make(chan fireRequest)
+	m.stopCh = make(chan struct{})
+	errCh := make(chan error, def.errorBuffer)
 	m.errCh = errCh
 
 	return m, errCh
 }
 
+// NewFromTable creates a state machine that starts in the initial state of the given
+// transition table, and a channel where any errors generated by the state machine will
+// be published.
+// Only the transitions in the table are followed, events that are not in the table
+// for the current state are rejected with ErrUnexpectedEvent. ErrNilTable is returned if the table is nil.
+//
+//nolint:gocritic
+func NewFromTable(id uuid.UUID, name string, table *Table, opts ...Option) (*Machine, chan error, error) {
+	if table == nil {
+		return nil, nil, ErrNilTable
+	}
+
+	m, errCh := New(id, name, table.enter(table.Initial(), nil), opts...)
+	m.table = table
+	m.currentName = table.Initial()
+
+	return m, errCh, nil
+}
+
 // ID returns the unique ID of the state machine
 func (m *Machine) ID() uuid.UUID {
 	return m.id
@@ -61,12 +128,24 @@ func (m *Machine) Name() string {
 
 // CurrentState returns the current state of the machine
 func (m *Machine) CurrentState() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return m.current
 }
 
+// CurrentName returns the name of the current state in the transition table, or an empty
+// string if the machine was not created from a transition table
+func (m *Machine) CurrentName() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.currentName
+}
+
 // CurrentDescription returns a description of the current state of the machine
 func (m *Machine) CurrentDescription() string {
-	return m.current.Description()
+	return m.CurrentState().Description()
 }
 
 // Run executes the state machine with the given context
@@ -77,7 +156,7 @@ func (m *Machine) CurrentDescription() string {
 // Run will close the error channel automatically when processing has been completed
 func (m *Machine) Run(ctx context.Context, eventCh <-chan Event, cleanup func() error) error {
 	if m.hasRun {
-		return errors.New("state machine has already been run and cannot be re-run, you need to create a new state machine")
+		return ErrAlreadyRun
 	}
 
 	m.hasRun = true
@@ -85,46 +164,183 @@ func (m *Machine) Run(ctx context.Context, eventCh <-chan Event, cleanup func()
 	l := logger.Logger()
 
 	defer close(m.errCh)
+	defer close(m.stopCh)
+	defer m.disarm()
+
+	m.processing.Lock()
+	err := enter(m.current, nil)
+	m.processing.Unlock()
+
+	if err != nil {
+		l.Error("Entering the initial state resulted in error", zap.Error(err))
+		m.fail(nil, err)
+	}
+
+	m.recordEntered()
+	defer m.recordStopped()
+	m.arm()
 
 	for {
 		select {
 		case <-ctx.Done():
 			l.Warn("Context has ended, stopping state machine")
 			return cleanup()
+		case fired := <-m.timerCh:
+			if fired.generation != m.generation {
+				continue
+			}
+
+			done, err := m.handle(ctx, l, fired.event, sourceTimer)
+			if err != nil {
+				m.fail(fired.event, err)
+			}
+
+			if done {
+				return nil
+			}
+		case req := <-m.fireCh:
+			done, err := m.handle(ctx, l, req.event, sourceFire)
+			if err != nil {
+				m.onError(req.event, err)
+			}
+
+			req.reply <- fireResult{state: m.current, err: err}
+
+			if done {
+				return nil
+			}
 		case event, ok := <-eventCh:
 			if !ok {
 				l.Warn("Incoming event channel has been closed, terminating state machine")
 				return nil
 			}
 
-			l.Info("Received event",
-				zap.String("name", event.Name()),
-				zap.String("source", event.Source()),
-				zap.String("id", event.ID().String()),
-				zap.String("timestamp", TimestampToString(event.Timestamp())),
-			)
-
-			err := m.current.Execute(event)
-
+			done, err := m.handle(ctx, l, event, sourceChannel)
 			if err != nil {
-				l.Error("Processing event resulted in error", zap.Error(err))
-				m.errCh <- err
-				continue
+				m.fail(event, err)
 			}
 
-			next := m.current.Next()
-			if next == nil {
+			if done {
 				return nil
 			}
+		}
+	}
+}
 
-			if next.ID() != m.current.ID() {
-				l.Info("State machine transitioning to new state",
-					zap.String("current", m.current.Description()),
-					zap.String("next", next.Description()),
-				)
-			}
+// handle processes a single event that arrived from the origin and returns true if the state machine
+// has finished, along with any errors that happened while processing the event
+func (m *Machine) handle(ctx context.Context, l *zap.Logger, event Event, origin string) (done bool, err error) {
+	m.processing.Lock()
+	defer m.processing.Unlock()
+
+	start := time.Now()
+	m.recordReceived(event, origin)
+
+	defer func() {
+		m.recordProcessed(event, start, err)
+	}()
+
+	l.Info("Received event",
+		zap.String("name", event.Name()),
+		zap.String("source", event.Source()),
+		zap.String("id", event.ID().String()),
+		zap.String("timestamp", TimestampToString(event.Timestamp())),
+	)
+
+	if err := m.journal(ctx, event); err != nil {
+		l.Error("Could not journal event, the event has not been processed", zap.Error(err))
+		return false, err
+	}
+
+	name, next, err := m.step(event)
+
+	if err != nil {
+		l.Error("Processing event resulted in error", zap.Error(err))
+		return false, err
+	}
 
-			m.current = next
+	if next == nil {
+		return true, nil
+	}
+
+	var errs []error
+
+	previous := m.current
+	transitioning := changed(m.current, next)
+
+	if transitioning {
+		l.Info("State machine transitioning to new state",
+			zap.String("current", m.current.Description()),
+			zap.String("next", next.Description()),
+		)
+
+		m.beforeTransition(next, event)
+		m.disarm()
+		m.recordTransition(name, next)
+
+		for _, err := range m.actions(event, next) {
+			l.Error("Running state entry or exit actions resulted in error", zap.Error(err))
+			errs = append(errs, err)
 		}
 	}
+
+	m.setCurrent(name, next)
+
+	if transitioning {
+		m.recordEntered()
+		m.arm()
+		m.afterTransition(previous, event)
+	}
+
+	if err := m.snapshot(ctx); err != nil {
+		l.Error("Could not record state machine snapshot", zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	if m.table != nil && m.table.IsFinal(name) {
+		l.Info("State machine has reached a final state", zap.String("state", name))
+		return true, errors.Join(errs...)
+	}
+
+	return false, errors.Join(errs...)
+}
+
+// setCurrent moves the machine to the next state
+func (m *Machine) setCurrent(name string, next State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.current = next
+	m.currentName = name
+}
+
+// actions runs the exit actions of the current state followed by the entry actions of the next
+// state, and returns any errors. An error in one does not prevent the other from running.
+func (m *Machine) actions(event Event, next State) []error {
+	var errs []error
+
+	if err := exit(m.current, event); err != nil {
+		errs = append(errs, fmt.Errorf("exiting %s: %w", m.current.Description(), err))
+	}
+
+	if err := enter(next, event); err != nil {
+		errs = append(errs, fmt.Errorf("entering %s: %w", next.Description(), err))
+	}
+
+	return errs
+}
+
+// step processes the event with the current state and returns the state the machine
+// should move to along with its name in the transition table.
+// A nil state means the state machine has finished.
+func (m *Machine) step(event Event) (string, State, error) {
+	if m.table != nil {
+		return m.table.next(m.currentName, m.current, event, m.execute)
+	}
+
+	if err := m.execute(m.current, event); err != nil {
+		return m.currentName, nil, err
+	}
+
+	return m.currentName, m.current.Next(), nil
 }
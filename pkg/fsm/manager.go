@@ -0,0 +1,575 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/logger"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+const (
+	// DefaultMaxRestarts is the default number of times a managed state machine is restarted after panicking
+	DefaultMaxRestarts = 3
+	// DefaultRestartWindow is the default time a managed state machine has to run without panicking
+	// for its restarts to be forgotten
+	DefaultRestartWindow = time.Hour
+	// DefaultShutdownTimeout is the default time the manager waits for its state machines to stop during cleanup
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+var (
+	// ErrManagerStopped is returned when an event is sent to a manager that has been shut down
+	ErrManagerStopped = errors.New("state machine manager has been shut down")
+	// ErrMachinePanicked is reported when a managed state machine panics
+	ErrMachinePanicked = errors.New("state machine panicked")
+	// ErrTooManyRestarts is reported when a managed state machine has panicked more than the maximum number of restarts
+	ErrTooManyRestarts = errors.New("state machine has been restarted too many times")
+)
+
+// Factory creates the state machine for the entity with the given key, e.g. by restoring it from a
+// snapshot with Restore or RestoreFromTable. It is called when the first event is sent for the key,
+// and again whenever the machine needs to be restarted.
+type Factory func(ctx context.Context, key string) (*Machine, chan error, error)
+
+// ManagerErrorFn is called with the errors published by managed state machines, and with any errors
+// the manager encounters creating, restarting or stopping them
+type ManagerErrorFn func(key string, err error)
+
+// ManagerCleanupFn is called when a managed state machine is stopped because it has been evicted or
+// the manager has been shut down
+type ManagerCleanupFn func(key string, m *Machine) error
+
+type managerOptions struct {
+	// idleTimeout is how long a machine can go without receiving an event before it is evicted, 0 never evicts
+	idleTimeout time.Duration
+	// maxRestarts is the number of times a machine is restarted after panicking before it is abandoned
+	maxRestarts int
+	// restartWindow is how long a machine has to run without panicking for its restarts to be reset
+	restartWindow time.Duration
+	// shutdownTimeout is how long Cleanup waits for the machines to stop
+	shutdownTimeout time.Duration
+	// clock is used to evict idle machines and to time the restart window
+	clock Clock
+	// onError is called with the errors of the managed machines
+	onError ManagerErrorFn
+	// cleanup is called when a machine is stopped
+	cleanup ManagerCleanupFn
+}
+
+// ManagerOption configures optional behaviour of a state machine manager
+type ManagerOption func(*managerOptions)
+
+// WithIdleTimeout evicts state machines that have not received an event for the duration.
+// An evicted machine is recreated by the factory when its next event is sent.
+func WithIdleTimeout(d time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithMaxRestarts sets the number of times a state machine is restarted after panicking
+// before it is abandoned, see WithRestartWindow
+func WithMaxRestarts(n int) ManagerOption {
+	return func(o *managerOptions) {
+		o.maxRestarts = n
+	}
+}
+
+// WithRestartWindow sets how long a state machine has to run without panicking for its restarts
+// to be reset, so only machines that keep panicking are abandoned
+func WithRestartWindow(d time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		o.restartWindow = d
+	}
+}
+
+// WithShutdownTimeout sets how long Cleanup waits for the state machines to stop
+func WithShutdownTimeout(d time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithManagerClock sets the clock used to evict idle state machines and to time the restart window,
+// e.g. a FakeClock in tests
+func WithManagerClock(c Clock) ManagerOption {
+	return func(o *managerOptions) {
+		o.clock = c
+	}
+}
+
+// WithManagerErrorHook sets the function called with the errors of the managed state machines.
+// By default the errors are logged.
+func WithManagerErrorHook(fn ManagerErrorFn) ManagerOption {
+	return func(o *managerOptions) {
+		o.onError = fn
+	}
+}
+
+// WithMachineCleanup sets the cleanup function passed to Run for each managed state machine
+func WithMachineCleanup(fn ManagerCleanupFn) ManagerOption {
+	return func(o *managerOptions) {
+		o.cleanup = fn
+	}
+}
+
+func defaultManagerOptions() managerOptions {
+	return managerOptions{
+		idleTimeout:     0,
+		maxRestarts:     DefaultMaxRestarts,
+		restartWindow:   DefaultRestartWindow,
+		shutdownTimeout: DefaultShutdownTimeout,
+		clock:           RealClock(),
+	}
+}
+
+// Manager runs a state machine for each entity, e.g. one per order, creating machines on demand
+// with a Factory and routing events to them by key. Machines that panic are restarted, idle
+// machines are evicted and all machines are stopped when the manager is shut down, which can
+// be tied to the service lifecycle by adding Cleanup as a cleanup function.
+type Manager struct {
+	mu       sync.Mutex
+	factory  Factory
+	options  managerOptions
+	ctx      context.Context
+	cancel   context.CancelFunc
+	machines map[string]*managed
+	// creating holds the machines being created by the factory, so that it is only called once per key
+	creating map[string]*creation
+	wg       sync.WaitGroup
+	stopped  bool
+	sweeper  Timer
+	errs     []error
+}
+
+// managed is a state machine run by the manager along with the channel its events are sent on.
+// The machine is replaced when it is restarted and swapped is closed to wake anyone waiting on it.
+type managed struct {
+	key      string
+	events   chan Event
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+	machine  *Machine
+	swapped  chan struct{}
+	lastUsed time.Time
+}
+
+// creation is a call to the factory for a key that the other callers for the key wait on,
+// done is closed once the machine has been started or the factory has failed
+type creation struct {
+	done chan struct{}
+	e    *managed
+	err  error
+}
+
+// NewManager creates a state machine manager that creates its machines with the factory
+func NewManager(factory Factory, opts ...ManagerOption) *Manager {
+	def := defaultManagerOptions()
+	for _, o := range opts {
+		o(&def)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &Manager{
+		factory:  factory,
+		options:  def,
+		ctx:      ctx,
+		cancel:   cancel,
+		machines: make(map[string]*managed),
+		creating: make(map[string]*creation),
+	}
+
+	if def.idleTimeout > 0 {
+		m.sweeper = def.clock.AfterFunc(def.idleTimeout, m.sweep)
+	}
+
+	return m
+}
+
+// Send passes the event to the state machine for the key, creating the machine if it is not running.
+// Send returns once the machine has received the event, errors processing the event are reported
+// to the error hook.
+func (m *Manager) Send(ctx context.Context, key string, event Event) error {
+	for {
+		e, err := m.get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case e.events <- event:
+			return nil
+		case <-e.done:
+			// the machine stopped before receiving the event, send it to its replacement
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Fire passes the event to the state machine for the key, creating the machine if it is not running,
+// and waits for it to be processed. It returns the state the machine is in afterwards or the error
+// that processing the event resulted in. As with Machine.Fire, it must not be called for a key from
+// the goroutine of that key's machine.
+func (m *Manager) Fire(ctx context.Context, key string, event Event) (State, error) {
+	for {
+		e, err := m.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		machine, swapped := e.current()
+
+		state, accepted, err := machine.fire(ctx, event)
+		if accepted || !errors.Is(err, ErrMachineStopped) {
+			return state, err
+		}
+
+		select {
+		case <-swapped:
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Get returns the running state machine for the key
+func (m *Manager) Get(key string) (*Machine, bool) {
+	m.mu.Lock()
+	e, ok := m.machines[key]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	machine, _ := e.current()
+
+	return machine, true
+}
+
+// Len returns the number of running state machines
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.machines)
+}
+
+// Keys returns the keys of the running state machines in sorted order
+func (m *Manager) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.machines))
+	for k := range m.machines {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Evict stops the state machine for the key and waits for it to finish.
+// It returns false if there is no running machine for the key.
+func (m *Manager) Evict(key string) bool {
+	m.mu.Lock()
+	e, ok := m.machines[key]
+	delete(m.machines, key)
+	m.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	e.cancel()
+	<-e.done
+
+	return true
+}
+
+// Shutdown stops all the state machines and waits for them to finish or for the context to end.
+// Events cannot be sent once the manager has been shut down. Any errors returned by the machines'
+// cleanup functions are returned together.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	if m.sweeper != nil {
+		m.sweeper.Stop()
+	}
+	m.mu.Unlock()
+
+	m.cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for state machines to stop: %w", ctx.Err())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return errors.Join(m.errs...)
+}
+
+// Cleanup shuts down the manager, waiting up to the shutdown timeout for the state machines to stop.
+// It can be added to a service with AddCleanupFunc.
+func (m *Manager) Cleanup(_ service.State) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.options.shutdownTimeout)
+	defer cancel()
+
+	return m.Shutdown(ctx)
+}
+
+// get returns the managed machine for the key, creating and starting it if it is not running. Callers that
+// ask for a key while its machine is being created wait for it, so the factory is only called once.
+func (m *Manager) get(ctx context.Context, key string) (*managed, error) {
+	m.mu.Lock()
+
+	if m.stopped {
+		m.mu.Unlock()
+		return nil, ErrManagerStopped
+	}
+
+	if e, ok := m.machines[key]; ok {
+		e.touch(m.options.clock.Now())
+		m.mu.Unlock()
+
+		return e, nil
+	}
+
+	if c, ok := m.creating[key]; ok {
+		m.mu.Unlock()
+
+		select {
+		case <-c.done:
+			return c.e, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c := &creation{done: make(chan struct{})}
+	m.creating[key] = c
+	m.mu.Unlock()
+
+	c.e, c.err = m.create(ctx, key)
+
+	m.mu.Lock()
+	delete(m.creating, key)
+	m.mu.Unlock()
+
+	close(c.done)
+
+	return c.e, c.err
+}
+
+// create creates the machine for the key with the factory and starts it. The factory is called
+// without holding the lock so a slow factory does not block other keys.
+func (m *Manager) create(ctx context.Context, key string) (*managed, error) {
+	machine, errCh, err := m.factory(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("creating state machine %s: %w", key, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return nil, ErrManagerStopped
+	}
+
+	runCtx, cancel := context.WithCancel(m.ctx)
+
+	e := &managed{
+		key:      key,
+		events:   make(chan Event),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		machine:  machine,
+		swapped:  make(chan struct{}),
+		lastUsed: m.options.clock.Now(),
+	}
+
+	m.machines[key] = e
+
+	m.wg.Add(1)
+
+	go m.supervise(runCtx, e, errCh)
+
+	return e, nil
+}
+
+// supervise runs the managed machine, restarting it with the factory if it panics. The restarts are
+// reset once the machine has run for the restart window without panicking.
+func (m *Manager) supervise(ctx context.Context, e *managed, errCh chan error) {
+	defer m.wg.Done()
+	defer close(e.done)
+	defer m.remove(e)
+
+	restarts := 0
+
+	for {
+		machine, _ := e.current()
+
+		go m.drain(e.key, errCh)
+
+		started := m.options.clock.Now()
+		err := m.run(ctx, e, machine)
+		if !errors.Is(err, ErrMachinePanicked) {
+			if err != nil {
+				m.report(e.key, err)
+				m.record(err)
+			}
+			return
+		}
+
+		m.report(e.key, err)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if m.options.clock.Now().Sub(started) >= m.options.restartWindow {
+			restarts = 0
+		}
+
+		restarts++
+		if restarts > m.options.maxRestarts {
+			m.report(e.key, fmt.Errorf("%w: %s", ErrTooManyRestarts, e.key))
+			return
+		}
+
+		machine, errCh, err = m.factory(ctx, e.key)
+		if err != nil {
+			m.report(e.key, fmt.Errorf("restarting state machine %s: %w", e.key, err))
+			return
+		}
+
+		e.swap(machine)
+	}
+}
+
+// run runs the machine, recovering from any panic
+func (m *Manager) run(ctx context.Context, e *managed, machine *Machine) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %s: %v", ErrMachinePanicked, e.key, r)
+		}
+	}()
+
+	return machine.Run(ctx, e.events, func() error {
+		if m.options.cleanup == nil {
+			return nil
+		}
+		return m.options.cleanup(e.key, machine)
+	})
+}
+
+// drain reports the errors published by a machine until it stops
+func (m *Manager) drain(key string, errCh chan error) {
+	for err := range errCh {
+		m.report(key, err)
+	}
+}
+
+func (m *Manager) report(key string, err error) {
+	if m.options.onError != nil {
+		m.options.onError(key, err)
+		return
+	}
+
+	logger.Logger().Error("Managed state machine error", zap.String("key", key), zap.Error(err))
+}
+
+// record keeps the errors returned by machines that stop while the manager is shutting down
+func (m *Manager) record(err error) {
+	if m.ctx.Err() == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.errs = append(m.errs, err)
+}
+
+// remove forgets the managed machine, unless it has already been replaced
+func (m *Manager) remove(e *managed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.machines[e.key] == e {
+		delete(m.machines, e.key)
+	}
+}
+
+// sweep evicts the machines that have been idle for longer than the idle timeout
+func (m *Manager) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return
+	}
+
+	now := m.options.clock.Now()
+
+	for key, e := range m.machines {
+		if now.Sub(e.idleSince()) >= m.options.idleTimeout {
+			delete(m.machines, key)
+			e.cancel()
+		}
+	}
+
+	m.sweeper = m.options.clock.AfterFunc(m.options.idleTimeout, m.sweep)
+}
+
+func (e *managed) current() (*Machine, chan struct{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.machine, e.swapped
+}
+
+func (e *managed) swap(machine *Machine) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.machine = machine
+	close(e.swapped)
+	e.swapped = make(chan struct{})
+}
+
+func (e *managed) touch(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastUsed = now
+}
+
+func (e *managed) idleSince() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.lastUsed
+}
@@ -0,0 +1,240 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+const kick = "kick"
+
+type managerFixture struct {
+	mu      sync.Mutex
+	created map[string]int
+	errs    []error
+	cleaned []string
+}
+
+func (f *managerFixture) factory(t *testing.T) fsm.Factory {
+	table, err := fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Transition(locked, coin, nil, unlocked).
+		Transition(locked, kick, func(fsm.State) bool { panic("kicked") }, unlocked).
+		Transition(unlocked, push, nil, locked).
+		Build()
+	require.NoError(t, err)
+
+	return func(_ context.Context, key string) (*fsm.Machine, chan error, error) {
+		f.mu.Lock()
+		f.created[key]++
+		f.mu.Unlock()
+
+		return fsm.NewFromTable(uuid.New(), key, table)
+	}
+}
+
+func (f *managerFixture) onError(_ string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errs = append(f.errs, err)
+}
+
+func (f *managerFixture) cleanup(key string, _ *fsm.Machine) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cleaned = append(f.cleaned, key)
+
+	return errors.New("cleanup " + key)
+}
+
+func (f *managerFixture) count(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.created[key]
+}
+
+func (f *managerFixture) errors() []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]error{}, f.errs...)
+}
+
+func (f *managerFixture) cleanedUp() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.cleaned...)
+}
+
+func newManagerFixture() *managerFixture {
+	return &managerFixture{created: make(map[string]int)}
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Events should be routed to a machine for each key", func(t *testing.T) {
+		f := newManagerFixture()
+		mgr := fsm.NewManager(f.factory(t))
+		defer mgr.Shutdown(ctx) //nolint:errcheck
+
+		state, err := mgr.Fire(ctx, "order-1", newEvent(coin))
+		require.NoError(t, err)
+		assert.Equal(t, unlocked, state.Description())
+
+		require.NoError(t, mgr.Send(ctx, "order-2", newEvent(coin)))
+
+		state, err = mgr.Fire(ctx, "order-1", newEvent(push))
+		require.NoError(t, err)
+		assert.Equal(t, locked, state.Description())
+
+		assert.Equal(t, []string{"order-1", "order-2"}, mgr.Keys())
+		assert.Equal(t, 1, f.count("order-1"))
+
+		m, ok := mgr.Get("order-2")
+		require.True(t, ok)
+		assert.Eventually(t, func() bool { return m.CurrentName() == unlocked }, time.Second, time.Millisecond)
+	})
+
+	t.Run("Concurrent first events for a key should create a single machine", func(t *testing.T) {
+		f := newManagerFixture()
+		factory := f.factory(t)
+		release := make(chan struct{})
+
+		// the factory blocks so that the other events arrive while the machine is being created
+		mgr := fsm.NewManager(func(ctx context.Context, key string) (*fsm.Machine, chan error, error) {
+			m, errCh, err := factory(ctx, key)
+			<-release
+			return m, errCh, err
+		}, fsm.WithManagerErrorHook(f.onError))
+		defer mgr.Shutdown(ctx) //nolint:errcheck
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, mgr.Send(ctx, "order-1", newEvent(coin)))
+			}()
+		}
+
+		assert.Eventually(t, func() bool { return f.count("order-1") > 0 }, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+
+		wg.Wait()
+
+		assert.Equal(t, 1, f.count("order-1"))
+		assert.Equal(t, 1, mgr.Len())
+	})
+
+	t.Run("Machines that panic should be restarted", func(t *testing.T) {
+		f := newManagerFixture()
+		mgr := fsm.NewManager(f.factory(t), fsm.WithManagerErrorHook(f.onError))
+		defer mgr.Shutdown(ctx) //nolint:errcheck
+
+		_, err := mgr.Fire(ctx, "order-1", newEvent(kick))
+		assert.ErrorIs(t, err, fsm.ErrMachineStopped)
+
+		state, err := mgr.Fire(ctx, "order-1", newEvent(coin))
+		require.NoError(t, err)
+		assert.Equal(t, unlocked, state.Description())
+
+		assert.Equal(t, 2, f.count("order-1"))
+		require.NotEmpty(t, f.errors())
+		assert.ErrorIs(t, f.errors()[0], fsm.ErrMachinePanicked)
+	})
+
+	t.Run("Machines should be abandoned after too many restarts", func(t *testing.T) {
+		f := newManagerFixture()
+		mgr := fsm.NewManager(f.factory(t), fsm.WithManagerErrorHook(f.onError), fsm.WithMaxRestarts(0))
+		defer mgr.Shutdown(ctx) //nolint:errcheck
+
+		_, err := mgr.Fire(ctx, "order-1", newEvent(kick))
+		assert.ErrorIs(t, err, fsm.ErrMachineStopped)
+
+		assert.Eventually(t, func() bool { return mgr.Len() == 0 }, time.Second, time.Millisecond)
+		assert.ErrorIs(t, errors.Join(f.errors()...), fsm.ErrTooManyRestarts)
+	})
+
+	t.Run("Restarts should be reset once a machine has run for the restart window", func(t *testing.T) {
+		f := newManagerFixture()
+		clock := fsm.NewFakeClock(time.Unix(0, 0))
+		mgr := fsm.NewManager(f.factory(t),
+			fsm.WithManagerErrorHook(f.onError),
+			fsm.WithManagerClock(clock),
+			fsm.WithMaxRestarts(1),
+			fsm.WithRestartWindow(time.Hour),
+		)
+		defer mgr.Shutdown(ctx) //nolint:errcheck
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, mgr.Send(ctx, "order-1", newEvent(coin)))
+			require.NoError(t, mgr.Send(ctx, "order-1", newEvent(push)))
+
+			clock.Advance(time.Hour)
+
+			_, err := mgr.Fire(ctx, "order-1", newEvent(kick))
+			assert.ErrorIs(t, err, fsm.ErrMachineStopped)
+
+			assert.Eventually(t, func() bool { return f.count("order-1") == i+2 }, time.Second, time.Millisecond)
+		}
+
+		assert.NotErrorIs(t, errors.Join(f.errors()...), fsm.ErrTooManyRestarts)
+		assert.Equal(t, []string{"order-1"}, mgr.Keys())
+	})
+
+	t.Run("Idle machines should be evicted", func(t *testing.T) {
+		f := newManagerFixture()
+		clock := fsm.NewFakeClock(time.Unix(0, 0))
+		mgr := fsm.NewManager(f.factory(t),
+			fsm.WithIdleTimeout(time.Minute),
+			fsm.WithManagerClock(clock),
+			fsm.WithMachineCleanup(f.cleanup),
+		)
+		defer mgr.Shutdown(ctx) //nolint:errcheck
+
+		require.NoError(t, mgr.Send(ctx, "order-1", newEvent(coin)))
+		clock.Advance(30 * time.Second)
+		require.NoError(t, mgr.Send(ctx, "order-2", newEvent(coin)))
+		clock.Advance(30 * time.Second)
+
+		assert.Equal(t, []string{"order-2"}, mgr.Keys())
+		assert.Eventually(t, func() bool { return len(f.cleanedUp()) == 1 }, time.Second, time.Millisecond)
+
+		_, err := mgr.Fire(ctx, "order-1", newEvent(coin))
+		require.NoError(t, err)
+		assert.Equal(t, 2, f.count("order-1"))
+	})
+
+	t.Run("Cleanup should stop all the machines", func(t *testing.T) {
+		f := newManagerFixture()
+		mgr := fsm.NewManager(f.factory(t), fsm.WithMachineCleanup(f.cleanup))
+
+		require.NoError(t, mgr.Send(ctx, "order-1", newEvent(coin)))
+		require.NoError(t, mgr.Send(ctx, "order-2", newEvent(coin)))
+
+		err := mgr.Cleanup(nil)
+		assert.ErrorContains(t, err, "cleanup order-1")
+		assert.ErrorContains(t, err, "cleanup order-2")
+		assert.ElementsMatch(t, []string{"order-1", "order-2"}, f.cleanedUp())
+		assert.Equal(t, 0, mgr.Len())
+
+		assert.ErrorIs(t, mgr.Send(ctx, "order-1", newEvent(coin)), fsm.ErrManagerStopped)
+	})
+}
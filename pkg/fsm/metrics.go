@@ -0,0 +1,139 @@
+package fsm
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"gitlab.com/gobl/gobl/pkg/metrics"
+)
+
+// The instrumentation types recorded by state machines created with WithInstrumentation.
+// The metrics are labelled with the name of the machine rather than its id, so the machines that
+// share a name, such as the machines run by a Manager, are aggregated together.
+const (
+	// MetricEventsReceived counts the events received by machine, event name and source
+	MetricEventsReceived metrics.InstrumentationType = iota
+	// MetricEventDuration observes how long events take to process by machine
+	MetricEventDuration
+	// MetricEventErrors counts the events that resulted in an error by machine and event name
+	MetricEventErrors
+	// MetricTransitions counts the transitions by machine and the states moved from and to
+	MetricTransitions
+	// MetricTimeInState observes how long the machine stayed in a state by machine and state
+	MetricTimeInState
+	// MetricMachinesInState is the number of running machines that are in a state by machine and state
+	MetricMachinesInState
+	// MetricStateEnteredAt is the unix time each running machine entered its current state by machine, id
+	// and state, so that machines stuck in a state can be alerted on with time() - fsm_state_entered_timestamp_seconds
+	MetricStateEnteredAt
+)
+
+// The sources events are counted with when they do not have a source of their own, by how they reached the machine
+const (
+	sourceTimer   = "timer"
+	sourceFire    = "fire"
+	sourceChannel = "channel"
+)
+
+// NewInstrumentation creates the instrumentation used by state machines in the given namespace.
+// Register it with the metrics server and pass it to every machine using WithInstrumentation,
+// the metrics are labelled with the name of each machine.
+func NewInstrumentation(namespace string) *metrics.Instrumentation {
+	return metrics.NewInstrumentation(namespace).
+		WithCounterVec(MetricEventsReceived, "fsm_events_received_total",
+			"Number of events received by the state machine", "machine", "event", "source").
+		WithHistogramVec(MetricEventDuration, "fsm_event_duration_seconds",
+			"Time taken for the state machine to process an event", []string{"machine"}, prometheus.DefBuckets).
+		WithCounterVec(MetricEventErrors, "fsm_event_errors_total",
+			"Number of events that resulted in an error", "machine", "event").
+		WithCounterVec(MetricTransitions, "fsm_transitions_total",
+			"Number of transitions between states", "machine", "from", "to").
+		WithHistogramVec(MetricTimeInState, "fsm_time_in_state_seconds",
+			"Time the state machine spent in a state before leaving it", []string{"machine", "state"}, prometheus.DefBuckets).
+		WithGaugeVec(MetricMachinesInState, "fsm_machines_in_state",
+			"Number of running state machines in a state", "machine", "state").
+		WithGaugeVec(MetricStateEnteredAt, "fsm_state_entered_timestamp_seconds",
+			"Unix time the running state machine entered its current state", "machine", "id", "state")
+}
+
+// stateLabel returns the name of the state in the transition table, or its description
+func (m *Machine) stateLabel(name string, state State) string {
+	if m.table != nil {
+		return name
+	}
+
+	return state.Description()
+}
+
+// recordReceived counts the event by its source, or by the origin it reached the machine from if it has no source
+func (m *Machine) recordReceived(event Event, origin string) {
+	i := m.options.instrumentation
+	if i == nil {
+		return
+	}
+
+	source := event.Source()
+	if source == "" {
+		source = origin
+	}
+
+	i.CounterVecs[MetricEventsReceived].WithLabelValues(m.name, event.Name(), source).Inc()
+}
+
+func (m *Machine) recordProcessed(event Event, start time.Time, err error) {
+	i := m.options.instrumentation
+	if i == nil {
+		return
+	}
+
+	i.HistogramVecs[MetricEventDuration].WithLabelValues(m.name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		i.CounterVecs[MetricEventErrors].WithLabelValues(m.name, event.Name()).Inc()
+	}
+}
+
+// recordEntered records the time the machine entered the current state and counts the machine in it
+func (m *Machine) recordEntered() {
+	m.enteredAt = m.options.clock.Now()
+
+	i := m.options.instrumentation
+	if i == nil {
+		return
+	}
+
+	state := m.stateLabel(m.currentName, m.current)
+
+	i.GaugeVecs[MetricMachinesInState].WithLabelValues(m.name, state).Inc()
+	i.GaugeVecs[MetricStateEnteredAt].WithLabelValues(m.name, m.id.String(), state).Set(float64(m.enteredAt.Unix()))
+}
+
+// recordStopped stops counting the machine in its current state once it has stopped running
+func (m *Machine) recordStopped() {
+	i := m.options.instrumentation
+	if i == nil {
+		return
+	}
+
+	state := m.stateLabel(m.currentName, m.current)
+
+	i.GaugeVecs[MetricMachinesInState].WithLabelValues(m.name, state).Dec()
+	i.GaugeVecs[MetricStateEnteredAt].DeleteLabelValues(m.name, m.id.String(), state)
+}
+
+// recordTransition records the transition and how long the machine was in the state it is leaving
+func (m *Machine) recordTransition(name string, next State) {
+	i := m.options.instrumentation
+	if i == nil {
+		return
+	}
+
+	from := m.stateLabel(m.currentName, m.current)
+	to := m.stateLabel(name, next)
+
+	i.CounterVecs[MetricTransitions].WithLabelValues(m.name, from, to).Inc()
+	i.HistogramVecs[MetricTimeInState].WithLabelValues(m.name, from).Observe(m.options.clock.Now().Sub(m.enteredAt).Seconds())
+	i.GaugeVecs[MetricMachinesInState].WithLabelValues(m.name, from).Dec()
+	i.GaugeVecs[MetricStateEnteredAt].DeleteLabelValues(m.name, m.id.String(), from)
+}
@@ -0,0 +1,126 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+// unsourcedEvent is an event that does not say where it came from
+type unsourcedEvent struct {
+	testEvent
+}
+
+func (unsourcedEvent) Source() string { return "" }
+
+func TestMachine_Instrumentation(t *testing.T) {
+	table, err := fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Transition(locked, coin, nil, unlocked).
+		Transition(unlocked, push, nil, locked).
+		Build()
+	require.NoError(t, err)
+
+	clock := fsm.NewFakeClock(time.Unix(1000, 0))
+	inst := fsm.NewInstrumentation("test")
+
+	m, _, err := fsm.NewFromTable(uuid.New(), "turnstile", table, fsm.WithClock(clock), fsm.WithInstrumentation(inst))
+	require.NoError(t, err)
+
+	// a second machine with the same name, as a Manager would run for another entity
+	other, _, err := fsm.NewFromTable(uuid.New(), "turnstile", table, fsm.WithClock(clock), fsm.WithInstrumentation(inst))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	otherCtx, stopOther := context.WithCancel(ctx)
+	otherDone := make(chan error)
+
+	go func() {
+		_ = m.Run(ctx, make(chan fsm.Event), func() error { return nil })
+	}()
+
+	go func() {
+		otherDone <- other.Run(otherCtx, make(chan fsm.Event), func() error { return nil })
+	}()
+
+	_, err = other.Fire(ctx, newEvent(coin))
+	require.NoError(t, err)
+
+	_, err = m.Fire(ctx, newEvent(coin))
+	require.NoError(t, err)
+
+	clock.Advance(5 * time.Second)
+
+	_, err = m.Fire(ctx, newEvent(push))
+	require.NoError(t, err)
+
+	_, err = m.Fire(ctx, newEvent(push))
+	require.Error(t, err)
+
+	_, err = m.Fire(ctx, unsourcedEvent{newEvent(push)})
+	require.Error(t, err)
+
+	t.Run("Events received should be counted by name and source", func(t *testing.T) {
+		events := inst.CounterVecs[fsm.MetricEventsReceived]
+		assert.Equal(t, 2.0, testutil.ToFloat64(events.WithLabelValues("turnstile", coin, "test")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(events.WithLabelValues("turnstile", push, "test")))
+	})
+
+	t.Run("Events without a source should be counted by how they reached the machine", func(t *testing.T) {
+		events := inst.CounterVecs[fsm.MetricEventsReceived]
+		assert.Equal(t, 1.0, testutil.ToFloat64(events.WithLabelValues("turnstile", push, "fire")))
+	})
+
+	t.Run("Errors should be counted by event name", func(t *testing.T) {
+		errs := inst.CounterVecs[fsm.MetricEventErrors]
+		assert.Equal(t, 2.0, testutil.ToFloat64(errs.WithLabelValues("turnstile", push)))
+		assert.Equal(t, 0.0, testutil.ToFloat64(errs.WithLabelValues("turnstile", coin)))
+	})
+
+	t.Run("Event latency should be observed for every event", func(t *testing.T) {
+		assert.Equal(t, 1, testutil.CollectAndCount(inst.HistogramVecs[fsm.MetricEventDuration]))
+	})
+
+	t.Run("Transitions should be counted by from and to state", func(t *testing.T) {
+		transitions := inst.CounterVecs[fsm.MetricTransitions]
+		assert.Equal(t, 2.0, testutil.ToFloat64(transitions.WithLabelValues("turnstile", locked, unlocked)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(transitions.WithLabelValues("turnstile", unlocked, locked)))
+	})
+
+	t.Run("Time in state should be observed when a state is left", func(t *testing.T) {
+		assert.Equal(t, 2, testutil.CollectAndCount(inst.HistogramVecs[fsm.MetricTimeInState]))
+	})
+
+	t.Run("Machines in state should count the machines that share a name", func(t *testing.T) {
+		inState := inst.GaugeVecs[fsm.MetricMachinesInState]
+		assert.Equal(t, 1.0, testutil.ToFloat64(inState.WithLabelValues("turnstile", locked)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(inState.WithLabelValues("turnstile", unlocked)))
+	})
+
+	t.Run("State entered at should be the time each machine entered its current state", func(t *testing.T) {
+		enteredAt := inst.GaugeVecs[fsm.MetricStateEnteredAt]
+		assert.Equal(t, 2, testutil.CollectAndCount(enteredAt))
+		assert.Equal(t, 1005.0, testutil.ToFloat64(enteredAt.WithLabelValues("turnstile", m.ID().String(), locked)))
+		assert.Equal(t, 1000.0, testutil.ToFloat64(enteredAt.WithLabelValues("turnstile", other.ID().String(), unlocked)))
+	})
+
+	t.Run("Machines in state should not count machines that have stopped", func(t *testing.T) {
+		stopOther()
+		require.NoError(t, <-otherDone)
+
+		inState := inst.GaugeVecs[fsm.MetricMachinesInState]
+		assert.Equal(t, 1.0, testutil.ToFloat64(inState.WithLabelValues("turnstile", locked)))
+		assert.Equal(t, 0.0, testutil.ToFloat64(inState.WithLabelValues("turnstile", unlocked)))
+		assert.Equal(t, 1, testutil.CollectAndCount(inst.GaugeVecs[fsm.MetricStateEnteredAt]))
+	})
+}
@@ -0,0 +1,99 @@
+package fsm
+
+import "gitlab.com/gobl/gobl/pkg/metrics"
+
+// DefaultErrorBuffer is the default size of the buffer of a state machine's error channel
+const DefaultErrorBuffer = 16
+
+type options struct {
+	// snapshotter records the state of the machine after each event has been processed
+	snapshotter Snapshotter
+	// journal records every event received by the machine before it is processed
+	journal Journal
+	// beforeTransition hooks are called before the machine leaves its current state
+	beforeTransition []TransitionHookFn
+	// afterTransition hooks are called once the machine has entered its next state
+	afterTransition []TransitionHookFn
+	// onError hooks are called whenever the machine publishes an error
+	onError []ErrorHookFn
+	// clock is used to arm state timers
+	clock Clock
+	// errorBuffer is the size of the error channel's buffer
+	errorBuffer int
+	// instrumentation records prometheus metrics for the machine
+	instrumentation *metrics.Instrumentation
+}
+
+// Option configures optional behaviour of a state machine
+type Option func(*options)
+
+// WithSnapshotter records a snapshot of the machine's current state with the snapshotter
+// after each event has been successfully processed
+func WithSnapshotter(s Snapshotter) Option {
+	return func(o *options) {
+		o.snapshotter = s
+	}
+}
+
+// WithJournal appends every event received by the machine to the journal before it is processed.
+// If an event cannot be journalled, the error is published and the event is not processed.
+func WithJournal(j Journal) Option {
+	return func(o *options) {
+		o.journal = j
+	}
+}
+
+// WithBeforeTransition adds hooks that are called before the machine leaves its current state,
+// before any exit actions are run
+func WithBeforeTransition(fns ...TransitionHookFn) Option {
+	return func(o *options) {
+		o.beforeTransition = append(o.beforeTransition, fns...)
+	}
+}
+
+// WithAfterTransition adds hooks that are called once the machine has entered its next state,
+// after any entry actions have been run
+func WithAfterTransition(fns ...TransitionHookFn) Option {
+	return func(o *options) {
+		o.afterTransition = append(o.afterTransition, fns...)
+	}
+}
+
+// WithErrorHook adds hooks that are called with every error the machine publishes on its error channel
+func WithErrorHook(fns ...ErrorHookFn) Option {
+	return func(o *options) {
+		o.onError = append(o.onError, fns...)
+	}
+}
+
+// WithClock sets the clock used for state timers, e.g. a FakeClock in tests
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithErrorBuffer sets the size of the error channel's buffer. Errors are dropped, and logged,
+// rather than blocking the state machine when the buffer is full.
+func WithErrorBuffer(size int) Option {
+	return func(o *options) {
+		o.errorBuffer = size
+	}
+}
+
+// WithInstrumentation records prometheus metrics for the machine, labelled with the machine's name.
+// The instrumentation must be created with NewInstrumentation.
+func WithInstrumentation(i *metrics.Instrumentation) Option {
+	return func(o *options) {
+		o.instrumentation = i
+	}
+}
+
+func defaultOptions() options {
+	return options{
+		snapshotter: nil,
+		journal:     nil,
+		clock:       RealClock(),
+		errorBuffer: DefaultErrorBuffer,
+	}
+}
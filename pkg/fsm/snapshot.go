@@ -0,0 +1,215 @@
+package fsm
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrSnapshotNotFound is returned by a Snapshotter when no snapshot has been recorded for a machine
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// Snapshot is the recorded state of a state machine
+type Snapshot struct {
+	// MachineID is the unique id of the state machine
+	MachineID uuid.UUID `json:"machineId"`
+	// Machine is the name of the state machine
+	Machine string `json:"machine"`
+	// State is the name of the current state in the transition table, or the
+	// description of the current state if the machine was not created from a table
+	State string `json:"state"`
+	// StateID is the unique id of the current state
+	StateID uuid.UUID `json:"stateId"`
+	// Data is the serialised current state, if the state implements encoding.BinaryMarshaler
+	Data []byte `json:"data"`
+	// Timestamp is the time the snapshot was taken as nanoseconds past epoch
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Snapshotter records and retrieves state machine snapshots so that a state machine can be
+// resumed after a restart
+type Snapshotter interface {
+	// Save records the snapshot, replacing any previous snapshot for the same machine
+	Save(ctx context.Context, snapshot Snapshot) error
+	// Load returns the last snapshot recorded for the machine or ErrSnapshotNotFound
+	Load(ctx context.Context, machineID uuid.UUID) (Snapshot, error)
+}
+
+// DecodeFn recreates a state from a snapshot
+type DecodeFn func(Snapshot) (State, error)
+
+// IDSetter can be implemented by states so that a machine restored from a snapshot with
+// RestoreFromTable keeps the state ID it had when the snapshot was taken
+type IDSetter interface {
+	SetID(uuid.UUID)
+}
+
+// TakeSnapshot returns a snapshot of the machine's current state. It waits for the event being
+// processed, if there is one, so it must not be called from a hook or a state.
+func (m *Machine) TakeSnapshot() (Snapshot, error) {
+	m.processing.Lock()
+	defer m.processing.Unlock()
+
+	return m.takeSnapshot()
+}
+
+// takeSnapshot returns a snapshot of the machine's current state, the caller must hold the processing lock
+func (m *Machine) takeSnapshot() (Snapshot, error) {
+	name := m.currentName
+	if m.table == nil {
+		name = m.current.Description()
+	}
+
+	s := Snapshot{
+		MachineID: m.id,
+		Machine:   m.name,
+		State:     name,
+		StateID:   m.current.ID(),
+		Timestamp: time.Now().UnixNano(),
+	}
+
+	if marshaler, ok := m.current.(encoding.BinaryMarshaler); ok {
+		data, err := marshaler.MarshalBinary()
+		if err != nil {
+			return s, fmt.Errorf("serialising state %s: %w", name, err)
+		}
+		s.Data = data
+	}
+
+	return s, nil
+}
+
+// snapshot records the current state with the configured snapshotter, if there is one
+func (m *Machine) snapshot(ctx context.Context) error {
+	if m.options.snapshotter == nil {
+		return nil
+	}
+
+	s, err := m.takeSnapshot()
+	if err != nil {
+		return err
+	}
+
+	if err := m.options.snapshotter.Save(ctx, s); err != nil {
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Restore creates a state machine from the last snapshot recorded for the machine id,
+// using decode to recreate the state. If there is no snapshot, the machine starts in
+// the init state. The returned machine continues to record snapshots with the snapshotter.
+//
+//nolint:gocritic
+func Restore(ctx context.Context, store Snapshotter, id uuid.UUID, name string, init State, decode DecodeFn,
+	opts ...Option) (*Machine, chan error, error) {
+	opts = append(opts, WithSnapshotter(store))
+
+	s, err := store.Load(ctx, id)
+	if errors.Is(err, ErrSnapshotNotFound) {
+		m, errCh := New(id, name, init, opts...)
+		return m, errCh, nil
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading snapshot: %w", err)
+	}
+
+	state, err := decode(s)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	m, errCh := New(id, name, state, opts...)
+
+	return m, errCh, nil
+}
+
+// RestoreFromTable creates a state machine from the last snapshot recorded for the machine id.
+// The state is created using the table and, if it implements encoding.BinaryUnmarshaler,
+// populated with the snapshot data. If it implements IDSetter, it is given the state ID in the
+// snapshot. If there is no snapshot, the machine starts in the
+// initial state of the table. The returned machine continues to record snapshots with the snapshotter.
+//
+//nolint:gocritic
+func RestoreFromTable(ctx context.Context, store Snapshotter, id uuid.UUID, name string, table *Table,
+	opts ...Option) (*Machine, chan error, error) {
+	if table == nil {
+		return nil, nil, ErrNilTable
+	}
+
+	opts = append(opts, WithSnapshotter(store))
+
+	s, err := store.Load(ctx, id)
+	if errors.Is(err, ErrSnapshotNotFound) {
+		return NewFromTable(id, name, table, opts...)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading snapshot: %w", err)
+	}
+
+	if _, ok := table.states[s.State]; !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownState, s.State)
+	}
+
+	state := table.enter(s.State, nil)
+
+	if unmarshaler, ok := state.(encoding.BinaryUnmarshaler); ok && len(s.Data) > 0 {
+		if err := unmarshaler.UnmarshalBinary(s.Data); err != nil {
+			return nil, nil, fmt.Errorf("deserialising state %s: %w", s.State, err)
+		}
+	}
+
+	if setter, ok := state.(IDSetter); ok && s.StateID != uuid.Nil {
+		setter.SetID(s.StateID)
+	}
+
+	m, errCh := New(id, name, state, opts...)
+	m.table = table
+	m.currentName = s.State
+
+	return m, errCh, nil
+}
+
+// MemorySnapshotter is an in-memory Snapshotter, useful for testing
+type MemorySnapshotter struct {
+	mu        sync.RWMutex
+	snapshots map[uuid.UUID]Snapshot
+}
+
+// NewMemorySnapshotter creates an empty in-memory Snapshotter
+func NewMemorySnapshotter() *MemorySnapshotter {
+	return &MemorySnapshotter{
+		snapshots: make(map[uuid.UUID]Snapshot),
+	}
+}
+
+// Save records the snapshot in memory
+func (s *MemorySnapshotter) Save(_ context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snapshots[snapshot.MachineID] = snapshot
+
+	return nil
+}
+
+// Load returns the last snapshot recorded for the machine
+func (s *MemorySnapshotter) Load(_ context.Context, machineID uuid.UUID) (Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, ok := s.snapshots[machineID]
+	if !ok {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+
+	return snapshot, nil
+}
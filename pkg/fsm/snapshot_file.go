@@ -0,0 +1,86 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+
+	iofs "gitlab.com/gobl/gobl/pkg/io/fs"
+)
+
+const (
+	snapshotDirMode  = 0o755
+	snapshotFileMode = 0o600
+)
+
+// FileSnapshotter is a Snapshotter that stores each machine's snapshot as a JSON file in a directory
+type FileSnapshotter struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileSnapshotter creates a Snapshotter that writes snapshots to the given directory,
+// creating the directory if it does not exist
+func NewFileSnapshotter(dir string) (*FileSnapshotter, error) {
+	if err := os.MkdirAll(dir, snapshotDirMode); err != nil {
+		return nil, fmt.Errorf("creating snapshot directory: %w", err)
+	}
+
+	return &FileSnapshotter{dir: dir}, nil
+}
+
+// Save writes the snapshot to the machine's snapshot file. The snapshot is written to a temporary file that
+// is synced to disk and renamed over the file, so a crash while saving leaves the previous snapshot intact.
+func (s *FileSnapshotter) Save(_ context.Context, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := iofs.WriteAtomically(s.path(snapshot.MachineID), snapshotFileMode, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return fmt.Errorf("writing snapshot file: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the machine's snapshot file
+func (s *FileSnapshotter) Load(_ context.Context, machineID uuid.UUID) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snapshot Snapshot
+
+	data, err := os.ReadFile(s.path(machineID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return snapshot, ErrSnapshotNotFound
+	}
+
+	if err != nil {
+		return snapshot, fmt.Errorf("reading snapshot file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+func (s *FileSnapshotter) path(machineID uuid.UUID) string {
+	return filepath.Join(s.dir, machineID.String()+".json")
+}
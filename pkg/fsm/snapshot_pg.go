@@ -0,0 +1,125 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.com/gobl/gobl/pkg/db/pg"
+)
+
+// DefaultSnapshotTable is the table used by the PgSnapshotter if no table name is given
+const DefaultSnapshotTable = "fsm_snapshots"
+
+// PgSnapshotter is a Snapshotter that stores snapshots in a Postgres table,
+// one row per state machine
+type PgSnapshotter struct {
+	pool  *pgxpool.Pool
+	table string
+}
+
+// NewPgSnapshotter connects to the Postgres database described by the configuration
+// and returns a Snapshotter that stores snapshots in the given table.
+// If table is empty, DefaultSnapshotTable is used.
+func NewPgSnapshotter(ctx context.Context, cfg pg.Configuration, table string) (*PgSnapshotter, error) {
+	pool, err := pgxpool.New(ctx, cfg.PgConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to snapshot database: %w", err)
+	}
+
+	return NewPgSnapshotterWithPool(pool, table), nil
+}
+
+// NewPgSnapshotterWithPool returns a Snapshotter that uses an existing connection pool
+// to store snapshots in the given table. If table is empty, DefaultSnapshotTable is used.
+func NewPgSnapshotterWithPool(pool *pgxpool.Pool, table string) *PgSnapshotter {
+	if table == "" {
+		table = DefaultSnapshotTable
+	}
+
+	return &PgSnapshotter{
+		pool:  pool,
+		table: pgx.Identifier{table}.Sanitize(),
+	}
+}
+
+// CreateTable creates the snapshot table if it does not already exist
+func (s *PgSnapshotter) CreateTable(ctx context.Context) error {
+	//nolint:gosec
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	machine_id UUID PRIMARY KEY,
+	machine TEXT NOT NULL,
+	state TEXT NOT NULL,
+	state_id UUID NOT NULL,
+	data BYTEA,
+	timestamp BIGINT NOT NULL
+)`, s.table)
+
+	if _, err := s.pool.Exec(ctx, query); err != nil {
+		return fmt.Errorf("creating snapshot table: %w", err)
+	}
+
+	return nil
+}
+
+// Save inserts or replaces the machine's snapshot
+func (s *PgSnapshotter) Save(ctx context.Context, snapshot Snapshot) error {
+	//nolint:gosec
+	query := fmt.Sprintf(`INSERT INTO %s (machine_id, machine, state, state_id, data, timestamp)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (machine_id) DO UPDATE SET
+	machine = EXCLUDED.machine,
+	state = EXCLUDED.state,
+	state_id = EXCLUDED.state_id,
+	data = EXCLUDED.data,
+	timestamp = EXCLUDED.timestamp`, s.table)
+
+	_, err := s.pool.Exec(ctx, query,
+		snapshot.MachineID,
+		snapshot.Machine,
+		snapshot.State,
+		snapshot.StateID,
+		snapshot.Data,
+		snapshot.Timestamp,
+	)
+	if err != nil {
+		return fmt.Errorf("saving snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Load returns the machine's snapshot
+func (s *PgSnapshotter) Load(ctx context.Context, machineID uuid.UUID) (Snapshot, error) {
+	//nolint:gosec
+	query := fmt.Sprintf(`SELECT machine_id, machine, state, state_id, data, timestamp FROM %s WHERE machine_id = $1`, s.table)
+
+	var snapshot Snapshot
+
+	err := s.pool.QueryRow(ctx, query, machineID).Scan(
+		&snapshot.MachineID,
+		&snapshot.Machine,
+		&snapshot.State,
+		&snapshot.StateID,
+		&snapshot.Data,
+		&snapshot.Timestamp,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return snapshot, ErrSnapshotNotFound
+	}
+
+	if err != nil {
+		return snapshot, fmt.Errorf("loading snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// Close closes the connection pool
+func (s *PgSnapshotter) Close() {
+	s.pool.Close()
+}
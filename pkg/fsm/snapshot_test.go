@@ -0,0 +1,139 @@
+package fsm_test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+func TestRestoreFromTable(t *testing.T) {
+	fileStore, err := fsm.NewFileSnapshotter(t.TempDir())
+	require.NoError(t, err)
+
+	stores := map[string]fsm.Snapshotter{
+		"memory": fsm.NewMemorySnapshotter(),
+		"file":   fileStore,
+	}
+
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			id := uuid.New()
+
+			_, err := store.Load(ctx, id)
+			require.ErrorIs(t, err, fsm.ErrSnapshotNotFound)
+
+			m, _, err := fsm.RestoreFromTable(ctx, store, id, "turnstile", table)
+			require.NoError(t, err)
+			assert.Equal(t, locked, m.CurrentName())
+
+			events := make(chan fsm.Event)
+			done := make(chan error)
+
+			go func() {
+				done <- m.Run(ctx, events, func() error { return nil })
+			}()
+
+			events <- newEvent(coin)
+			events <- newEvent(push)
+			close(events)
+			require.NoError(t, <-done)
+
+			snapshot, err := store.Load(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, id, snapshot.MachineID)
+			assert.Equal(t, locked, snapshot.State)
+			assert.Equal(t, m.CurrentState().ID(), snapshot.StateID)
+
+			restored, _, err := fsm.RestoreFromTable(ctx, store, id, "turnstile", table)
+			require.NoError(t, err)
+			assert.Equal(t, locked, restored.CurrentName())
+			assert.Equal(t, snapshot.StateID, restored.CurrentState().ID())
+
+			state, ok := restored.CurrentState().(*testState)
+			require.True(t, ok)
+			assert.Equal(t, 1, state.Coins)
+		})
+	}
+}
+
+func TestFileSnapshotter(t *testing.T) {
+	t.Run("Save should not leave temporary files when snapshotters share a directory", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+		id := uuid.New()
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			store, err := fsm.NewFileSnapshotter(dir)
+			require.NoError(t, err)
+
+			wg.Add(1)
+
+			go func(store *fsm.FileSnapshotter) {
+				defer wg.Done()
+				assert.NoError(t, store.Save(ctx, fsm.Snapshot{MachineID: id, State: locked}))
+			}(store)
+		}
+
+		wg.Wait()
+
+		store, err := fsm.NewFileSnapshotter(dir)
+		require.NoError(t, err)
+
+		snapshot, err := store.Load(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, locked, snapshot.State)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func TestMachine_TakeSnapshot(t *testing.T) {
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	m, _, err := fsm.NewFromTable(uuid.New(), "turnstile", table)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = m.Run(ctx, make(chan fsm.Event), func() error { return nil })
+	}()
+
+	t.Run("TakeSnapshot should be safe to call while events are processed", func(t *testing.T) {
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for i := 0; i < 10; i++ {
+				_, err := m.Fire(ctx, newEvent(fail))
+				assert.Error(t, err)
+			}
+		}()
+
+		for i := 0; i < 10; i++ {
+			snapshot, err := m.TakeSnapshot()
+			require.NoError(t, err)
+			assert.Equal(t, locked, snapshot.State)
+		}
+
+		<-done
+	})
+}
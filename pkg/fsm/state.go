@@ -44,3 +44,42 @@ func Next(current State, transitions ...Transition) State {
 	// so return the current state
 	return current
 }
+
+// ActionFn is an action that is run when a state is entered or exited. The event is the
+// event that caused the transition, or nil when the state machine starts.
+type ActionFn func(Event) error
+
+// Enterer can be implemented by states that need to run an action when the state machine enters them
+type Enterer interface {
+	// OnEnter is called when the state machine enters the state
+	OnEnter(Event) error
+}
+
+// Exiter can be implemented by states that need to run an action when the state machine leaves them
+type Exiter interface {
+	// OnExit is called when the state machine leaves the state
+	OnExit(Event) error
+}
+
+// enter runs the entry action of the state if it has one
+func enter(state State, event Event) error {
+	if e, ok := state.(Enterer); ok {
+		return e.OnEnter(event)
+	}
+
+	return nil
+}
+
+// exit runs the exit action of the state if it has one
+func exit(state State, event Event) error {
+	if e, ok := state.(Exiter); ok {
+		return e.OnExit(event)
+	}
+
+	return nil
+}
+
+// changed returns true if next is a different state to current
+func changed(current, next State) bool {
+	return next != nil && next.ID() != current.ID()
+}
@@ -0,0 +1,333 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoInitialState is returned when a transition table is built without an initial state
+	ErrNoInitialState = errors.New("no initial state has been defined")
+	// ErrDuplicateState is returned when a state is registered more than once
+	ErrDuplicateState = errors.New("state has already been registered")
+	// ErrUnknownState is returned when a transition refers to a state that has not been registered
+	ErrUnknownState = errors.New("state has not been registered")
+	// ErrUnreachableState is returned when a state cannot be reached from the initial state
+	ErrUnreachableState = errors.New("state cannot be reached from the initial state")
+	// ErrDeadEndState is returned when a state that is not final has no transitions out of it
+	ErrDeadEndState = errors.New("state has no transitions and is not a final state")
+	// ErrDuplicateGuard is returned when the same named guard function is used more than once for a state
+	// and event, or when an unguarded transition hides the transitions that follow it
+	ErrDuplicateGuard = errors.New("duplicate guard")
+	// ErrUnhandledTimeout is returned when a state has a timeout without a transition for its event
+	ErrUnhandledTimeout = errors.New("timeout is not handled")
+	// ErrNilTable is returned when a state machine is created from a nil transition table
+	ErrNilTable = errors.New("transition table is nil")
+)
+
+// TableTransition is a single row of a transition table. When the machine is in the From state
+// and receives an event with the given name, the event is executed by the current state and, if
+// the Guard passes, the machine transitions to the To state. A nil Guard always passes.
+type TableTransition struct {
+	From  string
+	Event string
+	Guard CheckFn
+	To    string
+}
+
+// Builder registers the states and transitions of a state machine up front so the
+// whole graph can be validated, and reviewed, in one place
+type Builder struct {
+	initial     string
+	states      map[string]NextFn
+	order       []string
+	finals      map[string]bool
+	transitions []TableTransition
+	timeouts    map[string][]Timeout
+	errs        []error
+}
+
+// NewBuilder creates an empty transition table builder
+func NewBuilder() *Builder {
+	return &Builder{
+		states:      make(map[string]NextFn),
+		order:       make([]string, 0),
+		finals:      make(map[string]bool),
+		transitions: make([]TableTransition, 0),
+		timeouts:    make(map[string][]Timeout),
+	}
+}
+
+// State registers a named state. The NextFn is called whenever the machine enters the state
+// with the state it is leaving (nil for the initial state) so any data can be carried across.
+func (b *Builder) State(name string, fn NextFn) *Builder {
+	if _, ok := b.states[name]; ok {
+		b.errs = append(b.errs, fmt.Errorf("%w: %s", ErrDuplicateState, name))
+		return b
+	}
+
+	b.states[name] = fn
+	b.order = append(b.order, name)
+
+	return b
+}
+
+// Initial registers a named state and marks it as the state the machine starts in
+func (b *Builder) Initial(name string, fn NextFn) *Builder {
+	b.initial = name
+	return b.State(name, fn)
+}
+
+// Final registers a named state and marks it as terminal. The state machine stops once
+// a final state has been entered.
+func (b *Builder) Final(name string, fn NextFn) *Builder {
+	b.finals[name] = true
+	return b.State(name, fn)
+}
+
+// Transition adds a row to the transition table. Transitions for the same state and event
+// are evaluated in the order they were added.
+func (b *Builder) Transition(from, event string, guard CheckFn, to string) *Builder {
+	b.transitions = append(b.transitions, TableTransition{
+		From:  from,
+		Event: event,
+		Guard: guard,
+		To:    to,
+	})
+
+	return b
+}
+
+// Timeout injects an event with the given name if the machine stays in the state for longer
+// than the duration. A transition for the event should be added to handle the timeout.
+func (b *Builder) Timeout(state string, after time.Duration, event string) *Builder {
+	b.timeouts[state] = append(b.timeouts[state], Timeout{After: after, Event: event})
+	return b
+}
+
+// Build validates the transition table and returns it. All problems found with the table
+// are returned together. The table is a copy, so changes made to the builder afterwards do not affect it.
+func (b *Builder) Build() (*Table, error) {
+	t := &Table{
+		initial:     b.initial,
+		states:      maps.Clone(b.states),
+		order:       slices.Clone(b.order),
+		finals:      maps.Clone(b.finals),
+		transitions: slices.Clone(b.transitions),
+		timeouts:    make(map[string][]Timeout, len(b.timeouts)),
+		index:       make(map[string]map[string][]TableTransition),
+	}
+
+	for state, timeouts := range b.timeouts {
+		t.timeouts[state] = slices.Clone(timeouts)
+	}
+
+	for _, tr := range t.transitions {
+		if _, ok := t.index[tr.From]; !ok {
+			t.index[tr.From] = make(map[string][]TableTransition)
+		}
+		t.index[tr.From][tr.Event] = append(t.index[tr.From][tr.Event], tr)
+	}
+
+	errs := append([]error{}, b.errs...)
+	errs = append(errs, t.validate()...)
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return t, nil
+}
+
+// Table is a validated transition table that can be used to create a state machine
+type Table struct {
+	initial     string
+	states      map[string]NextFn
+	order       []string
+	finals      map[string]bool
+	transitions []TableTransition
+	timeouts    map[string][]Timeout
+	index       map[string]map[string][]TableTransition
+}
+
+// Initial returns the name of the initial state
+func (t *Table) Initial() string {
+	return t.initial
+}
+
+// States returns the names of the registered states in the order they were registered
+func (t *Table) States() []string {
+	states := make([]string, len(t.order))
+	copy(states, t.order)
+
+	return states
+}
+
+// Transitions returns the rows of the transition table in the order they were added
+func (t *Table) Transitions() []TableTransition {
+	transitions := make([]TableTransition, len(t.transitions))
+	copy(transitions, t.transitions)
+
+	return transitions
+}
+
+// IsFinal returns true if the named state is a final state
+func (t *Table) IsFinal(name string) bool {
+	return t.finals[name]
+}
+
+// Accepts returns true if the table has a transition for the event from the named state
+func (t *Table) Accepts(name string, event string) bool {
+	return len(t.index[name][event]) > 0
+}
+
+// enter creates the named state using the state being left
+func (t *Table) enter(name string, previous State) State {
+	return t.states[name](previous)
+}
+
+// next executes the event against the current state and returns the name of the state
+// the machine should be in and the state itself. If no guard passes, the current state is returned.
+func (t *Table) next(name string, current State, event Event, execute func(State, Event) error) (string, State, error) {
+	rows := t.index[name][event.Name()]
+	if len(rows) == 0 {
+		return name, current, ErrUnexpectedEvent(event)
+	}
+
+	if err := execute(current, event); err != nil {
+		return name, current, err
+	}
+
+	for _, tr := range rows {
+		if tr.Guard == nil || tr.Guard(current) {
+			return tr.To, t.enter(tr.To, current), nil
+		}
+	}
+
+	return name, current, nil
+}
+
+func (t *Table) validate() []error {
+	var errs []error
+
+	if t.initial == "" {
+		errs = append(errs, ErrNoInitialState)
+	}
+
+	for _, tr := range t.transitions {
+		for _, name := range []string{tr.From, tr.To} {
+			if _, ok := t.states[name]; !ok {
+				errs = append(errs, fmt.Errorf("%w: %s (%s -[%s]-> %s)", ErrUnknownState, name, tr.From, tr.Event, tr.To))
+			}
+		}
+	}
+
+	for state, timeouts := range t.timeouts {
+		if _, ok := t.states[state]; !ok {
+			errs = append(errs, fmt.Errorf("%w: %s (timeout)", ErrUnknownState, state))
+			continue
+		}
+
+		for _, timeout := range timeouts {
+			if !t.Accepts(state, timeout.Event) {
+				errs = append(errs, fmt.Errorf("%w: %s has a timeout for %s but no transition for it",
+					ErrUnhandledTimeout, state, timeout.Event))
+			}
+		}
+	}
+
+	errs = append(errs, t.validateGuards()...)
+
+	if t.initial != "" {
+		reachable := t.reachable()
+		for _, name := range t.order {
+			if !reachable[name] {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrUnreachableState, name))
+			}
+		}
+	}
+
+	for _, name := range t.order {
+		if !t.finals[name] && len(t.index[name]) == 0 {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrDeadEndState, name))
+		}
+	}
+
+	return errs
+}
+
+func (t *Table) validateGuards() []error {
+	var errs []error
+
+	for _, from := range t.order {
+		for event, rows := range t.index[from] {
+			seen := make(map[string]bool)
+
+			for i, tr := range rows {
+				if tr.Guard == nil {
+					if i < len(rows)-1 {
+						errs = append(errs, fmt.Errorf("%w: unguarded transition %s -[%s]-> %s hides the transitions that follow it",
+							ErrDuplicateGuard, from, event, tr.To))
+					}
+
+					continue
+				}
+
+				name := GuardName(tr.Guard)
+				if !isNamedFunc(name) {
+					continue
+				}
+
+				if seen[name] {
+					errs = append(errs, fmt.Errorf("%w: %s is used more than once for %s -[%s]->",
+						ErrDuplicateGuard, name, from, event))
+				}
+				seen[name] = true
+			}
+		}
+	}
+
+	return errs
+}
+
+// reachable walks the table from the initial state and returns the states that can be reached
+func (t *Table) reachable() map[string]bool {
+	visited := map[string]bool{t.initial: true}
+	queue := []string{t.initial}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, rows := range t.index[current] {
+			for _, tr := range rows {
+				if !visited[tr.To] {
+					visited[tr.To] = true
+					queue = append(queue, tr.To)
+				}
+			}
+		}
+	}
+
+	return visited
+}
+
+// isNamedFunc returns true if the function name is of a top-level function. Closures, e.g. the guards
+// returned by a factory with different parameters, share their code so they cannot be told apart by name.
+func isNamedFunc(name string) bool {
+	if name == "" || strings.HasSuffix(name, "-fm") {
+		return false
+	}
+
+	for _, part := range strings.Split(name, ".")[1:] {
+		if len(part) > 4 && strings.HasPrefix(part, "func") && part[4] >= '0' && part[4] <= '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,129 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	t.Run("Build should return a table for a valid graph", func(t *testing.T) {
+		table, err := turnstile().Build()
+		require.NoError(t, err)
+		assert.Equal(t, locked, table.Initial())
+		assert.Equal(t, []string{locked, unlocked, broken}, table.States())
+		assert.True(t, table.IsFinal(broken))
+		assert.True(t, table.Accepts(locked, coin))
+		assert.False(t, table.Accepts(locked, push))
+	})
+
+	t.Run("Build should fail without an initial state", func(t *testing.T) {
+		_, err := fsm.NewBuilder().
+			State(locked, stateFn(locked)).
+			Transition(locked, coin, nil, locked).
+			Build()
+		assert.ErrorIs(t, err, fsm.ErrNoInitialState)
+	})
+
+	t.Run("Build should fail when a transition uses an unknown state", func(t *testing.T) {
+		_, err := turnstile().Transition(unlocked, coin, nil, "missing").Build()
+		assert.ErrorIs(t, err, fsm.ErrUnknownState)
+	})
+
+	t.Run("Build should fail when a state is registered twice", func(t *testing.T) {
+		_, err := turnstile().State(locked, stateFn(locked)).Build()
+		assert.ErrorIs(t, err, fsm.ErrDuplicateState)
+	})
+
+	t.Run("Build should fail when a state is unreachable", func(t *testing.T) {
+		_, err := turnstile().
+			State("orphan", stateFn("orphan")).
+			Transition("orphan", push, nil, locked).
+			Build()
+		assert.ErrorIs(t, err, fsm.ErrUnreachableState)
+	})
+
+	t.Run("Build should fail when a state is a dead end", func(t *testing.T) {
+		_, err := turnstile().
+			State("stuck", stateFn("stuck")).
+			Transition(unlocked, fail, nil, "stuck").
+			Build()
+		assert.ErrorIs(t, err, fsm.ErrDeadEndState)
+	})
+
+	t.Run("Build should fail when a guard is duplicated", func(t *testing.T) {
+		_, err := turnstile().Transition(locked, coin, hasCoin, locked).Build()
+		assert.ErrorIs(t, err, fsm.ErrDuplicateGuard)
+	})
+
+	t.Run("Build should allow guards made by the same factory with different parameters", func(t *testing.T) {
+		atLeast := func(n int) fsm.CheckFn {
+			return func(s fsm.State) bool { return n > 0 }
+		}
+
+		_, err := turnstile().
+			Transition(unlocked, coin, atLeast(1), broken).
+			Transition(unlocked, coin, atLeast(2), broken).
+			Build()
+		require.NoError(t, err)
+	})
+
+	t.Run("Build should fail when an unguarded transition hides other transitions", func(t *testing.T) {
+		_, err := turnstile().Transition(unlocked, push, hasCoin, unlocked).Build()
+		assert.ErrorIs(t, err, fsm.ErrDuplicateGuard)
+	})
+
+	t.Run("Build should not be affected by later changes to the builder", func(t *testing.T) {
+		b := turnstile()
+		table, err := b.Build()
+		require.NoError(t, err)
+
+		b.State("late", stateFn("late")).Transition(broken, push, nil, "late")
+
+		assert.Equal(t, []string{locked, unlocked, broken}, table.States())
+		assert.Len(t, table.Transitions(), 4)
+		assert.False(t, table.Accepts(broken, push))
+	})
+}
+
+func TestNewFromTable(t *testing.T) {
+	t.Run("NewFromTable should fail without a table", func(t *testing.T) {
+		_, _, err := fsm.NewFromTable(uuid.New(), "turnstile", nil)
+		assert.ErrorIs(t, err, fsm.ErrNilTable)
+	})
+
+	table, err := turnstile().Build()
+	require.NoError(t, err)
+
+	m, errCh, err := fsm.NewFromTable(uuid.New(), "turnstile", table)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, locked, m.CurrentName())
+
+	events := make(chan fsm.Event)
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(context.Background(), events, func() error { return nil })
+	}()
+
+	t.Run("Run should reject events that are not in the table", func(t *testing.T) {
+		events <- newEvent(push)
+		err := <-errCh
+		assert.ErrorIs(t, err, fsm.ErrEventNotHandled)
+		assert.Equal(t, locked, m.CurrentName())
+	})
+
+	t.Run("Run should follow the first transition whose guard passes", func(t *testing.T) {
+		events <- newEvent(coin)
+		events <- newEvent(push)
+		events <- newEvent(coin)
+		require.NoError(t, <-done)
+		assert.Equal(t, broken, m.CurrentName())
+	})
+}
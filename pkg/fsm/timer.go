@@ -0,0 +1,122 @@
+package fsm
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TimeoutSource is the source of the events injected by state timers
+const TimeoutSource = "fsm.timer"
+
+// Timeout injects an event with the given name into the state machine if it is still in the
+// same state once the duration has elapsed
+type Timeout struct {
+	// After is how long the machine has to stay in the state before the event is injected
+	After time.Duration
+	// Event is the name of the injected event
+	Event string
+}
+
+// Timed can be implemented by states that have timeouts. The timers are armed when the state
+// is entered and cancelled when it is exited.
+type Timed interface {
+	Timeouts() []Timeout
+}
+
+// TimeoutEvent is the event injected into the state machine when a state timer fires
+type TimeoutEvent struct {
+	id        uuid.UUID
+	name      string
+	timestamp int64
+	after     time.Duration
+}
+
+// NewTimeoutEvent creates a timeout event with the given name
+func NewTimeoutEvent(name string, after time.Duration, at time.Time) TimeoutEvent {
+	return TimeoutEvent{
+		id:        uuid.New(),
+		name:      name,
+		timestamp: at.UnixNano(),
+		after:     after,
+	}
+}
+
+// ID returns the unique id of the event
+func (e TimeoutEvent) ID() uuid.UUID {
+	return e.id
+}
+
+// Source returns TimeoutSource
+func (e TimeoutEvent) Source() string {
+	return TimeoutSource
+}
+
+// Name returns the name given to the timeout
+func (e TimeoutEvent) Name() string {
+	return e.name
+}
+
+// Timestamp returns the time the timer fired as nanoseconds past epoch
+func (e TimeoutEvent) Timestamp() int64 {
+	return e.timestamp
+}
+
+// After returns how long the state machine was in the state before the timer fired
+func (e TimeoutEvent) After() time.Duration {
+	return e.after
+}
+
+// timerFired is sent to the machine's loop when a timer fires. The generation is used to
+// discard timers that fired just before the state they were armed for was exited.
+type timerFired struct {
+	generation uint64
+	event      Event
+}
+
+// timeouts returns the timeouts for the current state from the transition table and the state itself
+func (m *Machine) timeouts() []Timeout {
+	var timeouts []Timeout
+
+	if m.table != nil {
+		timeouts = append(timeouts, m.table.timeouts[m.currentName]...)
+	}
+
+	if timed, ok := m.current.(Timed); ok {
+		timeouts = append(timeouts, timed.Timeouts()...)
+	}
+
+	return timeouts
+}
+
+// arm starts the timers for the current state
+func (m *Machine) arm() {
+	clock := m.options.clock
+	generation := m.generation
+
+	for _, t := range m.timeouts() {
+		timeout := t
+		m.timers = append(m.timers, clock.AfterFunc(timeout.After, func() {
+			fired := timerFired{
+				generation: generation,
+				event:      NewTimeoutEvent(timeout.Event, timeout.After, clock.Now()),
+			}
+
+			// the event is dropped if the machine stops before receiving it, so the clock is not blocked
+			select {
+			case m.timerCh <- fired:
+			case <-m.stopCh:
+			}
+		}))
+	}
+}
+
+// disarm cancels the timers for the current state
+func (m *Machine) disarm() {
+	for _, t := range m.timers {
+		t.Stop()
+	}
+
+	m.timers = nil
+	m.generation++
+}
@@ -0,0 +1,140 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/fsm"
+)
+
+const timeout = "timeout"
+
+func TestMachine_Timeouts(t *testing.T) {
+	t.Run("Build should fail if a timeout has no transition", func(t *testing.T) {
+		_, err := turnstile().Timeout(unlocked, time.Second, timeout).Build()
+		assert.ErrorIs(t, err, fsm.ErrUnhandledTimeout)
+	})
+
+	table, err := fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Transition(locked, coin, nil, unlocked).
+		Transition(unlocked, push, nil, locked).
+		Transition(unlocked, timeout, nil, locked).
+		Timeout(unlocked, 30*time.Second, timeout).
+		Build()
+	require.NoError(t, err)
+
+	clock := fsm.NewFakeClock(time.Now())
+	transitioned := make(chan fsm.Event)
+
+	m, _, err := fsm.NewFromTable(uuid.New(), "turnstile", table,
+		fsm.WithClock(clock),
+		fsm.WithAfterTransition(func(_, _ fsm.State, e fsm.Event) {
+			transitioned <- e
+		}),
+	)
+	require.NoError(t, err)
+
+	events := make(chan fsm.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(ctx, events, func() error { return nil })
+	}()
+
+	t.Run("timers should be armed when a state with timeouts is entered", func(t *testing.T) {
+		events <- newEvent(coin)
+		<-transitioned
+		assert.Equal(t, 1, clock.Timers())
+	})
+
+	t.Run("timers should not fire before the timeout", func(t *testing.T) {
+		clock.Advance(29 * time.Second)
+		assert.Equal(t, 1, clock.Timers())
+	})
+
+	t.Run("timers should inject a timeout event when they fire", func(t *testing.T) {
+		go clock.Advance(time.Second)
+		e := <-transitioned
+		assert.Equal(t, timeout, e.Name())
+		assert.Equal(t, fsm.TimeoutSource, e.Source())
+		assert.Equal(t, locked, m.CurrentName())
+	})
+
+	t.Run("timers should be cancelled when the state is exited", func(t *testing.T) {
+		events <- newEvent(coin)
+		<-transitioned
+		assert.Equal(t, 1, clock.Timers())
+
+		events <- newEvent(push)
+		<-transitioned
+		assert.Equal(t, 0, clock.Timers())
+		assert.Equal(t, locked, m.CurrentName())
+	})
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestFakeClock_Advance(t *testing.T) {
+	table, err := fsm.NewBuilder().
+		Initial(locked, stateFn(locked)).
+		State(unlocked, stateFn(unlocked)).
+		Transition(locked, coin, nil, unlocked).
+		Transition(unlocked, timeout, nil, locked).
+		Timeout(unlocked, 30*time.Second, timeout).
+		Build()
+	require.NoError(t, err)
+
+	clock := fsm.NewFakeClock(time.Now())
+	release := make(chan struct{})
+
+	m, _, err := fsm.NewFromTable(uuid.New(), "turnstile", table,
+		fsm.WithClock(clock),
+		fsm.WithAfterTransition(func(_, _ fsm.State, _ fsm.Event) {
+			<-release
+		}),
+	)
+	require.NoError(t, err)
+
+	events := make(chan fsm.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- m.Run(ctx, events, func() error { return nil })
+	}()
+
+	t.Run("Advance should return when the machine stops before receiving the timeout", func(t *testing.T) {
+		// the machine is held in the hook after entering the state, so it cannot receive the timeout
+		events <- newEvent(coin)
+		require.Eventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond)
+
+		advanced := make(chan struct{})
+
+		go func() {
+			clock.Advance(30 * time.Second)
+			close(advanced)
+		}()
+
+		require.Eventually(t, func() bool { return clock.Timers() == 0 }, time.Second, time.Millisecond)
+
+		cancel()
+		close(release)
+
+		select {
+		case <-advanced:
+		case <-time.After(time.Second):
+			t.Fatal("Advance is blocked on the timeout of the stopped machine")
+		}
+
+		require.NoError(t, <-done)
+	})
+}
@@ -1,5 +1,11 @@
 package fsm
 
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
 // CheckFn takes a state and determines whether or not it is ready to transition
 type CheckFn func(State) bool
 
@@ -18,3 +24,23 @@ type Transition struct {
 	// Next creates the next state for the state machine to transition to
 	Next NextFn
 }
+
+// GuardName returns the name of the function used as a guard without its package path,
+// e.g. transitions.HasCoin. An empty string is returned for a nil guard.
+func GuardName(fn CheckFn) string {
+	if fn == nil {
+		return ""
+	}
+
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return ""
+	}
+
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
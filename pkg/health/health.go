@@ -0,0 +1,291 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/logger"
+	"gitlab.com/gobl/gobl/pkg/metrics"
+)
+
+const (
+	// PathHealth is the path of the endpoint that runs every check
+	PathHealth = "/healthz"
+	// PathReady is the path of the endpoint that reports whether the service can receive traffic
+	PathReady = "/readyz"
+	// PathLive is the path of the endpoint that reports whether the service is alive
+	PathLive = "/livez"
+
+	// DefaultTimeout is how long a check can take unless a timeout is set with WithTimeout
+	DefaultTimeout = 5 * time.Second
+)
+
+// Status is the outcome of a check or a probe
+type Status string
+
+const (
+	// StatusOK is reported when every check passed
+	StatusOK Status = "ok"
+	// StatusDegraded is reported when only non-critical checks failed, the probe still succeeds
+	StatusDegraded Status = "degraded"
+	// StatusFailed is reported when a critical check failed, or the service is not ready
+	StatusFailed Status = "failed"
+)
+
+var (
+	// ErrDuplicateCheck is returned when a check is added with the name of an existing check
+	ErrDuplicateCheck = errors.New("duplicate health check")
+	// ErrNotReady is reported by the readiness probe before the service is ready and once it is shutting down
+	ErrNotReady = errors.New("service is not ready")
+	// ErrCheckPanicked is reported when a check panics
+	ErrCheckPanicked = errors.New("health check panicked")
+)
+
+// CheckFn checks a dependency of the service, e.g. that the database can be reached, and returns an error if it is unhealthy
+type CheckFn func(ctx context.Context) error
+
+type check struct {
+	name     string
+	fn       CheckFn
+	timeout  time.Duration
+	critical bool
+	liveness bool
+}
+
+// CheckOption configures a check
+type CheckOption func(*check)
+
+// WithTimeout sets how long the check can take before it fails, the default is DefaultTimeout
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = timeout
+	}
+}
+
+// WithCritical sets whether the probes fail when the check fails. Checks are critical by default,
+// a non-critical check that fails is reported but the probe reports the service as degraded and succeeds.
+func WithCritical(critical bool) CheckOption {
+	return func(c *check) {
+		c.critical = critical
+	}
+}
+
+// WithLiveness adds the check to the liveness probe as well as the readiness probe. Liveness checks
+// should only fail when the service cannot recover without being restarted, e.g. a deadlock.
+func WithLiveness() CheckOption {
+	return func(c *check) {
+		c.liveness = true
+	}
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Status   Status        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Critical bool          `json:"critical"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the outcome of a probe, it is the body of the response of the endpoints
+type Report struct {
+	Status Status            `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Health runs the health checks of a service and serves the health, readiness and liveness probes.
+// The service is not ready until SetReady is called, which bootstrap does once the service has been
+// initialised, and it stops being ready as soon as the service starts cleaning up.
+type Health struct {
+	mu     sync.RWMutex
+	checks []*check
+	ready  atomic.Bool
+}
+
+// New creates a health subsystem without any checks
+func New() *Health {
+	return &Health{}
+}
+
+// AddCheck adds a named check, which is run by the health and readiness probes
+func (h *Health) AddCheck(name string, fn CheckFn, opts ...CheckOption) error {
+	c := &check{
+		name:     name,
+		fn:       fn,
+		timeout:  DefaultTimeout,
+		critical: true,
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, existing := range h.checks {
+		if existing.name == name {
+			return fmt.Errorf("%w: %s", ErrDuplicateCheck, name)
+		}
+	}
+
+	h.checks = append(h.checks, c)
+
+	return nil
+}
+
+// SetReady sets whether the service is ready to receive traffic
+func (h *Health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Ready returns true if the service is ready to receive traffic
+func (h *Health) Ready() bool {
+	return h.ready.Load()
+}
+
+// Health runs every check
+func (h *Health) Health(ctx context.Context) Report {
+	return h.run(ctx, func(*check) bool { return true })
+}
+
+// Readiness reports that the service is not ready if SetReady has not been called, otherwise it runs every check
+func (h *Health) Readiness(ctx context.Context) Report {
+	if !h.Ready() {
+		return Report{Status: StatusFailed, Error: ErrNotReady.Error()}
+	}
+
+	return h.Health(ctx)
+}
+
+// Liveness runs the checks added with WithLiveness
+func (h *Health) Liveness(ctx context.Context) Report {
+	return h.run(ctx, func(c *check) bool { return c.liveness })
+}
+
+// Mount serves the probes on the metrics server at PathHealth, PathReady and PathLive
+func (h *Health) Mount(srv *metrics.Server) {
+	srv.Handle(PathHealth, h.Handler(h.Health))
+	srv.Handle(PathReady, h.Handler(h.Readiness))
+	srv.Handle(PathLive, h.Handler(h.Liveness))
+}
+
+// Handler returns an HTTP handler that responds with the report of the probe as JSON, with the
+// status 200 unless the probe failed, in which case the status is 503
+func (h *Health) Handler(probe func(context.Context) Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := probe(r.Context())
+
+		status := http.StatusOK
+		if report.Status == StatusFailed {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logger.Logger().Warn("Could not write health report", zap.String("path", r.URL.Path), zap.Error(err))
+		}
+	})
+}
+
+// run runs the checks matching the filter concurrently
+func (h *Health) run(ctx context.Context, filter func(*check) bool) Report {
+	h.mu.RLock()
+	checks := make([]*check, 0, len(h.checks))
+
+	for _, c := range h.checks {
+		if filter(c) {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+
+	for i, c := range checks {
+		wg.Add(1)
+
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+
+	var failed []string
+
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+
+		if results[i].Status == StatusOK {
+			continue
+		}
+
+		if c.critical {
+			report.Status = StatusFailed
+			failed = append(failed, c.name)
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		report.Error = fmt.Sprintf("failed checks: %v", failed)
+	}
+
+	return report
+}
+
+// run runs the check with its timeout, a check that does not return once the timeout has passed
+// is abandoned so that a hanging dependency cannot block the probe
+func (c *check) run(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("%w: %v", ErrCheckPanicked, r)
+			}
+		}()
+
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check did not complete: %w", ctx.Err())
+	}
+
+	r := Result{Status: StatusOK, Critical: c.critical, Duration: time.Since(start)}
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+	}
+
+	return r
+}
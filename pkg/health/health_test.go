@@ -0,0 +1,150 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/health"
+	"gitlab.com/gobl/gobl/pkg/metrics"
+)
+
+func pass(context.Context) error {
+	return nil
+}
+
+func fail(context.Context) error {
+	return errors.New("unreachable")
+}
+
+func probe(t *testing.T, h *health.Health, fn func(context.Context) health.Report) (int, health.Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.Handler(fn).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+
+	return rec.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Health should succeed when every check passes", func(t *testing.T) {
+		h := health.New()
+		require.NoError(t, h.AddCheck("db", pass))
+		require.NoError(t, h.AddCheck("cache", pass))
+
+		code, report := probe(t, h, h.Health)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Len(t, report.Checks, 2)
+	})
+
+	t.Run("Health should fail when a critical check fails", func(t *testing.T) {
+		h := health.New()
+		require.NoError(t, h.AddCheck("db", fail))
+
+		code, report := probe(t, h, h.Health)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusFailed, report.Status)
+		assert.Equal(t, "unreachable", report.Checks["db"].Error)
+	})
+
+	t.Run("Health should be degraded when a non-critical check fails", func(t *testing.T) {
+		h := health.New()
+		require.NoError(t, h.AddCheck("db", pass))
+		require.NoError(t, h.AddCheck("cache", fail, health.WithCritical(false)))
+
+		code, report := probe(t, h, h.Health)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusDegraded, report.Status)
+	})
+
+	t.Run("Health should fail a check that does not complete within its timeout", func(t *testing.T) {
+		h := health.New()
+		require.NoError(t, h.AddCheck("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+			return nil
+		}, health.WithTimeout(10*time.Millisecond)))
+
+		start := time.Now()
+		report := h.Health(context.Background())
+
+		assert.Equal(t, health.StatusFailed, report.Status)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("AddCheck should not add a check with a duplicate name", func(t *testing.T) {
+		h := health.New()
+		require.NoError(t, h.AddCheck("db", pass))
+		require.ErrorIs(t, h.AddCheck("db", pass), health.ErrDuplicateCheck)
+	})
+}
+
+func TestHealth_Readiness(t *testing.T) {
+	h := health.New()
+	require.NoError(t, h.AddCheck("db", pass))
+
+	t.Run("Readiness should fail until the service is ready", func(t *testing.T) {
+		code, report := probe(t, h, h.Readiness)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.ErrNotReady.Error(), report.Error)
+	})
+
+	t.Run("Readiness should run the checks once the service is ready", func(t *testing.T) {
+		h.SetReady(true)
+
+		code, report := probe(t, h, h.Readiness)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, report.Checks, "db")
+	})
+
+	t.Run("Readiness should fail once the service is shutting down", func(t *testing.T) {
+		h.SetReady(false)
+
+		code, _ := probe(t, h, h.Readiness)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	})
+}
+
+func TestHealth_Liveness(t *testing.T) {
+	h := health.New()
+	require.NoError(t, h.AddCheck("db", fail))
+	require.NoError(t, h.AddCheck("loop", pass, health.WithLiveness()))
+
+	t.Run("Liveness should only run the liveness checks", func(t *testing.T) {
+		code, report := probe(t, h, h.Liveness)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, report.Checks, 1)
+		assert.Contains(t, report.Checks, "loop")
+	})
+}
+
+func TestHealth_Mount(t *testing.T) {
+	h := health.New()
+	srv := metrics.DefaultServer(metrics.Config{Path: "/metrics"})
+
+	h.Mount(srv)
+
+	t.Run("Mount should serve the probes alongside the metrics", func(t *testing.T) {
+		for path, code := range map[string]int{
+			health.PathHealth: http.StatusOK,
+			health.PathReady:  http.StatusServiceUnavailable,
+			health.PathLive:   http.StatusOK,
+			"/metrics":        http.StatusOK,
+		} {
+			rec := httptest.NewRecorder()
+			srv.HTTPServer().Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, code, rec.Code, path)
+		}
+	})
+}
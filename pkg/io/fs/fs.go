@@ -1,7 +1,9 @@
 package fs
 
 import (
+	"io"
 	"os"
+	"path/filepath"
 )
 
 // FileExists checks if a file exists at the given path, the function will return an error if the file exists, but is not accessible
@@ -60,3 +62,54 @@ func ListSubFolders(path string) ([]string, error) {
 
 	return results, nil
 }
+
+// WriteAtomically replaces the file at the given path with what write writes, so that a crash leaves either the
+// previous or the new contents in the file. The contents are written to a temporary file in the same directory,
+// which must exist, synced to disk and then renamed over the file. Concurrent writes to the same path each use
+// their own temporary file, and the last one to be renamed wins.
+func WriteAtomically(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// the temporary file is only left behind if it could not be renamed over the file
+	renamed := false
+	defer func() {
+		if !renamed {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := writeAndSync(tmp, perm, write); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	renamed = true
+
+	return nil
+}
+
+// writeAndSync writes to the file, sets its permissions, syncs it to disk and closes it
+func writeAndSync(f *os.File, perm os.FileMode, write func(w io.Writer) error) error {
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
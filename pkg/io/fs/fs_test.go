@@ -1,6 +1,10 @@
 package fs_test
 
 import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"gitlab.com/gobl/gobl/pkg/io/fs"
@@ -57,3 +61,47 @@ func Test_FileExists(t *testing.T) {
 		t.Errorf("fs.go does not exist")
 	}
 }
+
+func Test_WriteAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+
+	for _, contents := range []string{"first", "second"} {
+		err := fs.WriteAtomically(path, 0o600, func(w io.Writer) error {
+			_, err := io.WriteString(w, contents)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("could not write the file - %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("could not read the file - %v", err)
+		}
+
+		if string(data) != contents {
+			t.Errorf("Expected the file to contain %q, but found %q", contents, data)
+		}
+	}
+
+	errWrite := errors.New("write failed")
+
+	err := fs.WriteAtomically(path, 0o600, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("Expected the write error, but got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "second" {
+		t.Errorf("Expected a failed write to leave the file unchanged, but found %q", data)
+	}
+
+	files, _ := fs.ListFiles(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected no temporary files to be left, but found %v", files)
+	}
+}
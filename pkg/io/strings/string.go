@@ -129,3 +129,19 @@ func MkString(sep string, input ...string) string {
 
 	return b.String()
 }
+
+// EscapeGlob escapes the characters that have a special meaning in a glob pattern, such as the patterns used
+// by Redis to match keys, so that they only match themselves
+func EscapeGlob(input string) string {
+	b := gs.Builder{}
+
+	for _, r := range input {
+		if gs.ContainsRune(`\*?[]`, r) {
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -222,3 +222,30 @@ func TestToQuotedCsv(t *testing.T) {
 		})
 	}
 }
+
+func TestEscapeGlob(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "metacharacters-are-escaped",
+			input: `state:a*b?c[d]e\f`,
+			want:  `state:a\*b\?c\[d\]e\\f`,
+		},
+		{
+			name:  "other-characters-are-unchanged",
+			input: "state:nodes/^a-b",
+			want:  "state:nodes/^a-b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeGlob(tt.input); got != tt.want {
+				t.Errorf("EscapeGlob() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -106,15 +107,28 @@ func New(level zapcore.Level, writer io.Writer) *zap.Logger {
 // Max Age of log files: 30 days
 // Use compression: false
 func ConfiguredLumberjackLogger() *lumberjack.Logger {
+	return ConfiguredLumberjackLoggerIn(viper.GetViper())
+}
+
+// ConfiguredLumberjackLoggerIn returns a lumberjack logger in the same way as ConfiguredLumberjackLogger,
+// using the configuration in the given Viper instance
+func ConfiguredLumberjackLoggerIn(v *viper.Viper) *lumberjack.Logger {
 	return LumberjackLogger(
-		config.Get(config.LogFilePathKey).String(defaultLogFileName),
-		config.Get(config.LogFileMaxSize).Int(defaultMaxSizeMB),
-		config.Get(config.LogFileMaxBackups).Int(defaultMaxBackupFiles),
-		config.Get(config.LogFileMaxAge).Int(defaultMaxAgeDays),
-		config.Get(config.LogFileCompress).Bool(defaultUseCompression),
+		config.In(v, config.LogFilePathKey).String(defaultLogFileName),
+		config.In(v, config.LogFileMaxSize).Int(defaultMaxSizeMB),
+		config.In(v, config.LogFileMaxBackups).Int(defaultMaxBackupFiles),
+		config.In(v, config.LogFileMaxAge).Int(defaultMaxAgeDays),
+		config.In(v, config.LogFileCompress).Bool(defaultUseCompression),
 	)
 }
 
+// NewIn creates a new zap logger at the log level, writing to Stdout and the log file, configured in the
+// given Viper instance. Unlike Get, it does not share its core or writer with any other logger.
+func NewIn(v *viper.Viper) *zap.Logger {
+	writer := zapcore.NewMultiWriteSyncer(zapcore.AddSync(ConfiguredLumberjackLoggerIn(v)), zapcore.AddSync(os.Stdout))
+	return zap.New(zapcore.NewCore(ZapEncoder(), writer, ApplicationLogLevelIn(v)), zap.AddCaller())
+}
+
 // ConsoleLogger returns a zap logger that writes to Stdout by default at INFO level
 func ConsoleLogger() *zap.Logger {
 	c := zapcore.NewCore(ZapEncoder(), zapcore.AddSync(os.Stdout), zapcore.InfoLevel)
@@ -124,9 +138,14 @@ func ConsoleLogger() *zap.Logger {
 // ApplicationLogLevel returns the log level defined in the
 // application configuration file
 func ApplicationLogLevel() zapcore.Level {
+	return ApplicationLogLevelIn(viper.GetViper())
+}
+
+// ApplicationLogLevelIn returns the log level defined in the given Viper instance
+func ApplicationLogLevelIn(v *viper.Viper) zapcore.Level {
 	var level zapcore.Level
 
-	switch strings.ToUpper(config.Get(config.LogLevelKey).String("")) {
+	switch strings.ToUpper(config.In(v, config.LogLevelKey).String("")) {
 	case "DEBUG":
 		level = zapcore.DebugLevel
 	case "INFO":
@@ -26,6 +26,7 @@ type Instrumentation struct {
 	HistogramVecs map[InstrumentationType]*prometheus.HistogramVec
 	Summaries     map[InstrumentationType]prometheus.Summary
 	SummaryVecs   map[InstrumentationType]*prometheus.SummaryVec
+	Custom        map[InstrumentationType]prometheus.Collector
 }
 
 // NewInstrumentation creates a new Instrumentation instance with the given namespace.
@@ -40,6 +41,7 @@ func NewInstrumentation(namespace string) *Instrumentation {
 		HistogramVecs: make(map[InstrumentationType]*prometheus.HistogramVec),
 		Summaries:     make(map[InstrumentationType]prometheus.Summary),
 		SummaryVecs:   make(map[InstrumentationType]*prometheus.SummaryVec),
+		Custom:        make(map[InstrumentationType]prometheus.Collector),
 	}
 }
 
@@ -137,6 +139,13 @@ func (i *Instrumentation) WithSummaryVec(t InstrumentationType, name, help strin
 	return i
 }
 
+// WithCollector adds a custom Prometheus collector, e.g. one that reports the statistics of a cache,
+// to the Instrumentation instance.
+func (i *Instrumentation) WithCollector(t InstrumentationType, c prometheus.Collector) *Instrumentation {
+	i.Custom[t] = c
+	return i
+}
+
 // Collectors returns all Prometheus collectors that have been added to the Instrumentation instance.
 func (i *Instrumentation) Collectors() []prometheus.Collector {
 	var collectors []prometheus.Collector
@@ -164,5 +173,8 @@ func (i *Instrumentation) Collectors() []prometheus.Collector {
 	for _, summaryVec := range i.SummaryVecs {
 		collectors = append(collectors, summaryVec)
 	}
+	for _, custom := range i.Custom {
+		collectors = append(collectors, custom)
+	}
 	return collectors
 }
@@ -20,6 +20,7 @@ type Server struct {
 	mu      sync.Mutex
 	cfg     Config
 	srv     *http.Server
+	mux     *http.ServeMux
 	reg     *prometheus.Registry
 	log     *zap.Logger
 	started bool
@@ -58,6 +59,7 @@ func WithRegistry(cfg Config, srv *http.Server, reg *prometheus.Registry, opts p
 	return &Server{
 		cfg:     cfg,
 		srv:     srv,
+		mux:     mux,
 		reg:     reg,
 		log:     logger.Logger().With(zap.String("service", "metrics")),
 		started: false,
@@ -104,6 +106,15 @@ func (s *Server) Stop() error {
 	return s.srv.Close()
 }
 
+// Handle registers the handler for the pattern on the metrics server, so that other endpoints such as
+// health checks can be served alongside the metrics. Handlers must be registered before the server is started.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mux.Handle(pattern, handler)
+}
+
 // Registry returns the prometheus registry used by the metrics server.
 func (s *Server) Registry() *prometheus.Registry {
 	return s.reg
@@ -137,3 +148,17 @@ func (s *Server) Register(instrumentation *Instrumentation) error {
 
 	return nil
 }
+
+// RegisterCollectors registers the given prometheus collectors with the metrics server.
+func (s *Server) RegisterCollectors(collectors ...prometheus.Collector) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range collectors {
+		if err := s.reg.Register(c); err != nil {
+			return fmt.Errorf("failed to register collector: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -66,6 +66,10 @@ func (p *Properties) UnmarshalBinary(data []byte) error {
 		return err
 	}
 
+	if *p == nil {
+		*p = NewProperties()
+	}
+
 	// now we have to convert the data to their intended types
 	// because the json types are limited
 	for k := range properties {
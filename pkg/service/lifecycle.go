@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDuplicateComponent is returned when two components have the same name
+	ErrDuplicateComponent = errors.New("duplicate component")
+	// ErrUnknownDependency is returned when a component depends on a component that has not been added
+	ErrUnknownDependency = errors.New("unknown component dependency")
+	// ErrDependencyCycle is returned when components depend on each other
+	ErrDependencyCycle = errors.New("component dependency cycle")
+	// ErrComponentTimeout is returned when a component does not start or stop within its timeout
+	ErrComponentTimeout = errors.New("component timed out")
+)
+
+// LifecycleFunc starts or stops a component
+type LifecycleFunc func(ctx context.Context, state State) error
+
+// Component is a part of a service that is started after, and stopped before, the components it depends on
+type Component struct {
+	// Name identifies the component so that other components can depend on it
+	Name string
+	// DependsOn are the names of the components that must be started before this component
+	DependsOn []string
+	// Start starts the component, it is optional
+	Start LifecycleFunc
+	// Stop stops the component, it is optional and is only called if the component was started
+	Stop LifecycleFunc
+	// StartTimeout is how long Start can take, 0 is no limit
+	StartTimeout time.Duration
+	// StopTimeout is how long Stop can take, 0 is no limit
+	StopTimeout time.Duration
+}
+
+// Lifecycle starts components in the order of their dependencies and stops them in the reverse order
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []Component
+	// started are the components that have been started, in the order they were started
+	started []Component
+}
+
+// NewLifecycle creates a lifecycle for the components
+func NewLifecycle(components ...Component) *Lifecycle {
+	return &Lifecycle{components: components}
+}
+
+// Add adds components to the lifecycle, they are started by the next call to Start
+func (l *Lifecycle) Add(components ...Component) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.components = append(l.components, components...)
+}
+
+// Order returns the components in the order they are started, every component comes after the
+// components it depends on and otherwise keeps the order it was added in
+func (l *Lifecycle) Order() ([]Component, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return order(l.components)
+}
+
+// Start starts the components that have not been started in the order of their dependencies.
+// If a component fails to start, the components that were started are stopped in the reverse order
+// and the errors are returned.
+func (l *Lifecycle) Start(ctx context.Context, state State) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ordered, err := order(l.components)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range ordered {
+		if l.isStarted(c.Name) {
+			continue
+		}
+
+		if err := call(ctx, state, c.Start, c.StartTimeout); err != nil {
+			err = fmt.Errorf("starting %s: %w", c.Name, err)
+			return errors.Join(err, l.stop(ctx, state))
+		}
+
+		l.started = append(l.started, c)
+	}
+
+	return nil
+}
+
+// Stop stops the components that were started in the reverse order they were started. Every component
+// is stopped even if stopping another component fails, and all the errors are returned joined together.
+func (l *Lifecycle) Stop(ctx context.Context, state State) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stop(ctx, state)
+}
+
+func (l *Lifecycle) stop(ctx context.Context, state State) error {
+	var errs []error
+
+	for i := len(l.started) - 1; i >= 0; i-- {
+		c := l.started[i]
+		if err := call(ctx, state, c.Stop, c.StopTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", c.Name, err))
+		}
+	}
+
+	l.started = nil
+
+	return errors.Join(errs...)
+}
+
+func (l *Lifecycle) isStarted(name string) bool {
+	for _, c := range l.started {
+		if c.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// call calls fn with a context that is cancelled after the timeout. fn is abandoned if it does not
+// return once the context is done, so a component that ignores its context cannot block the service.
+func call(ctx context.Context, state State, fn LifecycleFunc, timeout time.Duration) error {
+	if fn == nil {
+		return nil
+	}
+
+	if timeout <= 0 {
+		return fn(ctx, state)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- fn(ctx, state)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("%w after %s: %w", ErrComponentTimeout, timeout, ctx.Err())
+	}
+}
+
+// order sorts the components topologically by their dependencies
+func order(components []Component) ([]Component, error) {
+	byName := make(map[string]Component, len(components))
+
+	for _, c := range components {
+		if _, ok := byName[c.Name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateComponent, c.Name)
+		}
+
+		byName[c.Name] = c
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	marks := make(map[string]int, len(components))
+	ordered := make([]Component, 0, len(components))
+
+	var visit func(c Component, path []string) error
+
+	visit = func(c Component, path []string) error {
+		switch marks[c.Name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %v", ErrDependencyCycle, append(path, c.Name))
+		}
+
+		marks[c.Name] = visiting
+
+		for _, name := range c.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, c.Name, name)
+			}
+
+			if err := visit(dep, append(path, c.Name)); err != nil {
+				return err
+			}
+		}
+
+		marks[c.Name] = visited
+		ordered = append(ordered, c)
+
+		return nil
+	}
+
+	for _, c := range components {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
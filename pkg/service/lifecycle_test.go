@@ -0,0 +1,118 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+// calls records the order components are started and stopped in
+type calls struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *calls) record(call string, err error) service.LifecycleFunc {
+	return func(context.Context, service.State) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.calls = append(c.calls, call)
+
+		return err
+	}
+}
+
+func (c *calls) component(name string, err error, dependsOn ...string) service.Component {
+	return service.Component{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start:     c.record("start "+name, err),
+		Stop:      c.record("stop "+name, nil),
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Start should start components after their dependencies", func(t *testing.T) {
+		c := &calls{}
+		l := service.NewLifecycle(
+			c.component("api", nil, "db", "cache"),
+			c.component("cache", nil, "db"),
+			c.component("db", nil),
+		)
+
+		require.NoError(t, l.Start(ctx, nil))
+		assert.Equal(t, []string{"start db", "start cache", "start api"}, c.calls)
+	})
+
+	t.Run("Stop should stop components in reverse order", func(t *testing.T) {
+		c := &calls{}
+		l := service.NewLifecycle(c.component("db", nil), c.component("api", nil, "db"))
+
+		require.NoError(t, l.Start(ctx, nil))
+		require.NoError(t, l.Stop(ctx, nil))
+		assert.Equal(t, []string{"start db", "start api", "stop api", "stop db"}, c.calls)
+	})
+
+	t.Run("Stop should stop every component and join the errors", func(t *testing.T) {
+		errA, errB := errors.New("a"), errors.New("b")
+		l := service.NewLifecycle(
+			service.Component{Name: "a", Stop: func(context.Context, service.State) error { return errA }},
+			service.Component{Name: "b", Stop: func(context.Context, service.State) error { return errB }},
+		)
+
+		require.NoError(t, l.Start(ctx, nil))
+
+		err := l.Stop(ctx, nil)
+		require.ErrorIs(t, err, errA)
+		require.ErrorIs(t, err, errB)
+	})
+
+	t.Run("Start should stop the started components when a component fails", func(t *testing.T) {
+		c := &calls{}
+		errFailed := errors.New("failed")
+		l := service.NewLifecycle(
+			c.component("db", nil),
+			c.component("api", errFailed, "db"),
+			c.component("worker", nil, "api"),
+		)
+
+		require.ErrorIs(t, l.Start(ctx, nil), errFailed)
+		assert.Equal(t, []string{"start db", "start api", "stop db"}, c.calls)
+	})
+
+	t.Run("Start should fail if a component does not start within its timeout", func(t *testing.T) {
+		l := service.NewLifecycle(service.Component{
+			Name:         "slow",
+			StartTimeout: 10 * time.Millisecond,
+			Start: func(context.Context, service.State) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+		})
+
+		require.ErrorIs(t, l.Start(ctx, nil), service.ErrComponentTimeout)
+	})
+
+	t.Run("Order should fail on invalid dependencies", func(t *testing.T) {
+		c := &calls{}
+
+		_, err := service.NewLifecycle(c.component("a", nil, "b"), c.component("b", nil, "a")).Order()
+		require.ErrorIs(t, err, service.ErrDependencyCycle)
+
+		_, err = service.NewLifecycle(c.component("a", nil, "missing")).Order()
+		require.ErrorIs(t, err, service.ErrUnknownDependency)
+
+		_, err = service.NewLifecycle(c.component("a", nil), c.component("a", nil)).Order()
+		require.ErrorIs(t, err, service.ErrDuplicateComponent)
+	})
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 
 	"gitlab.com/gobl/gobl/pkg/property"
@@ -14,7 +16,8 @@ type Service interface {
 	Init(ctx context.Context, state State) error
 	// AddInitFunc adds the given initialisation functions to the service
 	AddInitFunc(fns ...InitFunc) Service
-	// Cleanup executes the cleanup functions that were provided to the service in the order they were provided
+	// Cleanup executes the cleanup functions that were provided to the service in the order they were provided,
+	// every function is executed even if an earlier one fails
 	Cleanup(state State) error
 	// AddCleanupFunc adds the given cleanup functions to the service
 	AddCleanupFunc(fns ...CleanupFunc) Service
@@ -30,12 +33,66 @@ type Service interface {
 	GetProperty(name string) (property.Property, error)
 }
 
+// ErrUnsupported is returned when a service does not implement the optional interface a feature needs
+var ErrUnsupported = errors.New("not supported by the service")
+
+// ComponentAdder is implemented by services that run components, such as the bootstrap service
+type ComponentAdder interface {
+	// AddComponent adds components that are started in the order of their dependencies once the
+	// initialisation functions have run, and stopped in the reverse order before the cleanup functions run
+	AddComponent(components ...Component) Service
+}
+
+// AddComponent adds the components to the service, ErrUnsupported is returned if the service is not a ComponentAdder
+func AddComponent(s Service, components ...Component) error {
+	a, ok := s.(ComponentAdder)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot run components", ErrUnsupported, s)
+	}
+
+	a.AddComponent(components...)
+
+	return nil
+}
+
+// ReadinessReporter is implemented by services that report whether they are ready, such as the bootstrap service
+type ReadinessReporter interface {
+	// WithReadiness sets the readiness that is reported as ready once the service has been initialised,
+	// and as not ready as soon as the service starts cleaning up, e.g. health.Health
+	WithReadiness(r Readiness) Service
+}
+
+// WithReadiness sets the readiness of the service, ErrUnsupported is returned if the service is not a ReadinessReporter
+func WithReadiness(s Service, r Readiness) error {
+	rr, ok := s.(ReadinessReporter)
+	if !ok {
+		return fmt.Errorf("%w: %T cannot report readiness", ErrUnsupported, s)
+	}
+
+	rr.WithReadiness(r)
+
+	return nil
+}
+
+// ContextCleaner is implemented by services whose cleanup can be bounded by a context, such as the bootstrap
+// service. The cmd package uses it to limit the cleanup to the shutdown grace period.
+type ContextCleaner interface {
+	// CleanupContext executes the cleanup of the service in the same way as Cleanup, passing the context to
+	// the parts of the cleanup that accept one so that they stop waiting once it is done
+	CleanupContext(ctx context.Context, state State) error
+}
+
 // InitFunc is a function that can be called to perform an initialisation task for a service
 type InitFunc func(ctx context.Context, state State) error
 
 // CleanupFunc is a function that can be called to perform a cleanup task for a service on graceful exit
 type CleanupFunc func(state State) error
 
+// Readiness is told whether the service is ready to receive traffic
+type Readiness interface {
+	SetReady(ready bool)
+}
+
 // RunFunc is a function that can be run in place of the long running service
 type RunFunc func(ctx context.Context, state State) error
 
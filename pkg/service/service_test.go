@@ -0,0 +1,39 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/bootstrap"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+// plain is a service that only implements the Service interface
+type plain struct {
+	service.Service
+}
+
+func TestAddComponent(t *testing.T) {
+	t.Run("AddComponent should add the components to a service that runs them", func(t *testing.T) {
+		require.NoError(t, service.AddComponent(bootstrap.New(), service.Component{Name: "db"}))
+	})
+
+	t.Run("AddComponent should return ErrUnsupported if the service cannot run components", func(t *testing.T) {
+		require.ErrorIs(t, service.AddComponent(plain{}, service.Component{Name: "db"}), service.ErrUnsupported)
+	})
+}
+
+type readiness struct{}
+
+func (readiness) SetReady(bool) {}
+
+func TestWithReadiness(t *testing.T) {
+	t.Run("WithReadiness should set the readiness of a service that reports it", func(t *testing.T) {
+		require.NoError(t, service.WithReadiness(bootstrap.New(), readiness{}))
+	})
+
+	t.Run("WithReadiness should return ErrUnsupported if the service cannot report readiness", func(t *testing.T) {
+		require.ErrorIs(t, service.WithReadiness(plain{}, readiness{}), service.ErrUnsupported)
+	})
+}
@@ -1,7 +1,62 @@
 package service
 
+import (
+	"context"
+	"errors"
+)
+
 // State provides an interface for implementing a state store for your application
 // The default state store is an in-memory state store accessible by the service
 // By implementing this interface users can implement state stores that can utilise
 // different services such as Redis, Consul, Etcd etc. for storing their state
 type State interface{}
+
+// ErrStateNotFound is returned by a StateStore when a key does not exist
+var ErrStateNotFound = errors.New("state not found")
+
+// StateEventType is the kind of change made to a key in a StateStore
+type StateEventType int
+
+const (
+	// StateSet is sent when a key is set
+	StateSet StateEventType = iota
+	// StateDeleted is sent when a key is deleted
+	StateDeleted
+)
+
+// String returns the name of the event type
+func (t StateEventType) String() string {
+	switch t {
+	case StateSet:
+		return "set"
+	case StateDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// StateEvent is a change made to a key in a StateStore
+type StateEvent struct {
+	Type StateEventType `json:"type"`
+	Key  string         `json:"key"`
+	// Value is the new value of the key, it is empty when the key was deleted
+	Value []byte `json:"value,omitempty"`
+}
+
+// StateStore is a key/value store that can be used as the state of a service, so that replicas of the
+// service can share coordination data. The pkg/state package has in-memory, file and Redis implementations.
+type StateStore interface {
+	// Get returns the value of the key, or ErrStateNotFound if it does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set sets the value of the key
+	Set(ctx context.Context, key string, value []byte) error
+	// Delete deletes the key, deleting a key that does not exist is not an error
+	Delete(ctx context.Context, key string) error
+	// Keys returns the keys that start with the prefix, an empty prefix returns every key
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	// Watch returns a channel that receives the changes made to the keys that start with the prefix
+	// until the context is done, when the channel is closed. A receiver that falls behind misses events,
+	// the stores in pkg/state drop the events of a receiver that is more than state.DefaultWatchBuffer behind.
+	Watch(ctx context.Context, prefix string) (<-chan StateEvent, error)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gitlab.com/gobl/gobl/pkg/property"
+)
+
+// ErrUnexpectedState is returned when the state passed to a typed function is not of the type it expects
+var ErrUnexpectedState = errors.New("unexpected state")
+
+// TypedInitFunc is an InitFunc that receives the state of the service as its own type
+type TypedInitFunc[S any] func(ctx context.Context, state S) error
+
+// TypedCleanupFunc is a CleanupFunc that receives the state of the service as its own type
+type TypedCleanupFunc[S any] func(state S) error
+
+// TypedRunFunc is a RunFunc that receives the state of the service as its own type
+type TypedRunFunc[S any] func(ctx context.Context, state S) error
+
+// TypedService is a Service whose functions receive the state as S, so that the state is type checked
+// at compile time instead of every function starting with a type assertion
+type TypedService[S any] interface {
+	// AddInitFunc adds the given initialisation functions to the service
+	AddInitFunc(fns ...TypedInitFunc[S]) TypedService[S]
+	// AddCleanupFunc adds the given cleanup functions to the service
+	AddCleanupFunc(fns ...TypedCleanupFunc[S]) TypedService[S]
+	// WithRunFunc passes an alternative run function to the service
+	WithRunFunc(fn TypedRunFunc[S]) TypedService[S]
+	// SetProperties sets the properties for the service
+	SetProperties(properties property.Properties) error
+	// AddProperty adds a property to the service
+	AddProperty(name string, p property.Property) TypedService[S]
+	// GetProperty returns the requested property
+	GetProperty(name string) (property.Property, error)
+	// Service returns the service that runs the typed functions, e.g. to pass to cmd.Execute
+	Service() Service
+}
+
+// StateAs returns the state as S, or ErrUnexpectedState if it is another type
+func StateAs[S any](state State) (S, error) {
+	s, ok := state.(S)
+	if !ok {
+		return s, fmt.Errorf("%w: %T", ErrUnexpectedState, state)
+	}
+
+	return s, nil
+}
+
+// UntypedInit adapts a TypedInitFunc to an InitFunc
+func UntypedInit[S any](fn TypedInitFunc[S]) InitFunc {
+	return func(ctx context.Context, state State) error {
+		s, err := StateAs[S](state)
+		if err != nil {
+			return err
+		}
+
+		return fn(ctx, s)
+	}
+}
+
+// UntypedCleanup adapts a TypedCleanupFunc to a CleanupFunc
+func UntypedCleanup[S any](fn TypedCleanupFunc[S]) CleanupFunc {
+	return func(state State) error {
+		s, err := StateAs[S](state)
+		if err != nil {
+			return err
+		}
+
+		return fn(s)
+	}
+}
+
+// UntypedRun adapts a TypedRunFunc to a RunFunc
+func UntypedRun[S any](fn TypedRunFunc[S]) RunFunc {
+	return func(ctx context.Context, state State) error {
+		s, err := StateAs[S](state)
+		if err != nil {
+			return err
+		}
+
+		return fn(ctx, s)
+	}
+}
+
+// typed adapts a Service to a TypedService
+type typed[S any] struct {
+	svc Service
+}
+
+// Typed returns a TypedService that adds its functions to the service, adapting them to receive the state as S
+func Typed[S any](svc Service) TypedService[S] {
+	return &typed[S]{svc: svc}
+}
+
+func (t *typed[S]) AddInitFunc(fns ...TypedInitFunc[S]) TypedService[S] {
+	for _, fn := range fns {
+		t.svc.AddInitFunc(UntypedInit(fn))
+	}
+
+	return t
+}
+
+func (t *typed[S]) AddCleanupFunc(fns ...TypedCleanupFunc[S]) TypedService[S] {
+	for _, fn := range fns {
+		t.svc.AddCleanupFunc(UntypedCleanup(fn))
+	}
+
+	return t
+}
+
+func (t *typed[S]) WithRunFunc(fn TypedRunFunc[S]) TypedService[S] {
+	if fn == nil {
+		t.svc.WithRunFunc(nil)
+		return t
+	}
+
+	t.svc.WithRunFunc(UntypedRun(fn))
+
+	return t
+}
+
+func (t *typed[S]) SetProperties(properties property.Properties) error {
+	return t.svc.SetProperties(properties)
+}
+
+func (t *typed[S]) AddProperty(name string, p property.Property) TypedService[S] {
+	t.svc.AddProperty(name, p)
+	return t
+}
+
+func (t *typed[S]) GetProperty(name string) (property.Property, error) {
+	return t.svc.GetProperty(name)
+}
+
+func (t *typed[S]) Service() Service {
+	return t.svc
+}
@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/bootstrap"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+type counterState struct {
+	inits    int
+	runs     int
+	cleanups int
+}
+
+func TestTyped(t *testing.T) {
+	app := bootstrap.NewTyped[*counterState]().
+		AddInitFunc(func(_ context.Context, s *counterState) error {
+			s.inits++
+			return nil
+		}).
+		AddCleanupFunc(func(s *counterState) error {
+			s.cleanups++
+			return nil
+		}).
+		WithRunFunc(func(_ context.Context, s *counterState) error {
+			s.runs++
+			return nil
+		})
+
+	t.Run("Typed functions should receive the state as its own type", func(t *testing.T) {
+		st := &counterState{}
+		svc := app.Service()
+
+		require.NoError(t, svc.Init(context.Background(), st))
+		require.NoError(t, svc.RunFunction()(context.Background(), st))
+		require.NoError(t, svc.Cleanup(st))
+
+		assert.Equal(t, &counterState{inits: 1, runs: 1, cleanups: 1}, st)
+	})
+
+	t.Run("Typed functions should fail with a state of another type", func(t *testing.T) {
+		svc := app.Service()
+
+		require.ErrorIs(t, svc.Init(context.Background(), "not a counter"), service.ErrUnexpectedState)
+		require.ErrorIs(t, svc.Cleanup(nil), service.ErrUnexpectedState)
+	})
+}
+
+func TestStateAs(t *testing.T) {
+	t.Run("StateAs should return the state as the type", func(t *testing.T) {
+		st := &counterState{}
+
+		s, err := service.StateAs[*counterState](st)
+		require.NoError(t, err)
+		assert.Same(t, st, s)
+	})
+
+	t.Run("StateAs should fail if the state is another type", func(t *testing.T) {
+		_, err := service.StateAs[*counterState](1)
+		require.ErrorIs(t, err, service.ErrUnexpectedState)
+	})
+}
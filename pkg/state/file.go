@@ -0,0 +1,136 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	iofs "gitlab.com/gobl/gobl/pkg/io/fs"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+const (
+	// fileMode is the mode of the state file
+	fileMode = 0o600
+	// fileDirMode is the mode of the directory created for the state file
+	fileDirMode = 0o755
+)
+
+// File is a StateStore that keeps the state in memory and writes it to a JSON file on every change,
+// so that the state survives restarts. Watch only receives the changes made through the same store.
+type File struct {
+	// mem holds the state, it is only changed once the change has been written to the file
+	mem *Memory
+	// mu orders the writes to the file so that it matches the last change
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile creates a state store backed by the file, loading the state that is already in it.
+// The file is created on the first change if it does not exist.
+func NewFile(path string) (*File, error) {
+	f := &File{mem: NewMemory(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	if len(data) == 0 {
+		return f, nil
+	}
+
+	if err := json.Unmarshal(data, &f.mem.data); err != nil {
+		return nil, fmt.Errorf("decoding state file: %w", err)
+	}
+
+	if f.mem.data == nil {
+		f.mem.data = make(map[string][]byte)
+	}
+
+	return f, nil
+}
+
+// Get returns the value of the key, or service.ErrStateNotFound if it does not exist
+func (f *File) Get(ctx context.Context, key string) ([]byte, error) {
+	return f.mem.Get(ctx, key)
+}
+
+// Set writes the state with the value of the key to the file, and then sets the key. The key is not set,
+// and the watchers are not told about it, if the file cannot be written.
+func (f *File) Set(ctx context.Context, key string, value []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data := f.mem.snapshot()
+	data[key] = value
+
+	if err := f.save(data); err != nil {
+		return err
+	}
+
+	return f.mem.Set(ctx, key, value)
+}
+
+// Delete writes the state without the key to the file, and then deletes the key. The key is not deleted
+// if the file cannot be written.
+func (f *File) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data := f.mem.snapshot()
+	if _, ok := data[key]; !ok {
+		return nil
+	}
+
+	delete(data, key)
+
+	if err := f.save(data); err != nil {
+		return err
+	}
+
+	return f.mem.Delete(ctx, key)
+}
+
+// Keys returns the keys that start with the prefix
+func (f *File) Keys(ctx context.Context, prefix string) ([]string, error) {
+	return f.mem.Keys(ctx, prefix)
+}
+
+// Watch returns the changes made through the store to the keys that start with the prefix, until the
+// context is done
+func (f *File) Watch(ctx context.Context, prefix string) (<-chan service.StateEvent, error) {
+	return f.mem.Watch(ctx, prefix)
+}
+
+// save writes the state to a temporary file in the same directory, syncs it to disk and renames it over the
+// file, so that a crash while saving leaves either the previous or the new state in the file
+func (f *File) save(state map[string][]byte) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), fileDirMode); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+
+	if err := iofs.WriteAtomically(f.path, fileMode, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+
+	return nil
+}
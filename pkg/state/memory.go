@@ -0,0 +1,98 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+// Memory is a StateStore that keeps the state in memory, it is only shared within the process
+type Memory struct {
+	mu       sync.RWMutex
+	data     map[string][]byte
+	watchers watchers
+}
+
+// NewMemory creates an empty in-memory state store
+func NewMemory() *Memory {
+	return &Memory{data: make(map[string][]byte)}
+}
+
+// Get returns the value of the key, or service.ErrStateNotFound if it does not exist
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	v, ok := m.data[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", service.ErrStateNotFound, key)
+	}
+
+	return slices.Clone(v), nil
+}
+
+// Set sets the value of the key
+func (m *Memory) Set(_ context.Context, key string, value []byte) error {
+	value = slices.Clone(value)
+
+	m.mu.Lock()
+	m.data[key] = value
+	m.mu.Unlock()
+
+	m.watchers.notify(service.StateEvent{Type: service.StateSet, Key: key, Value: slices.Clone(value)})
+
+	return nil
+}
+
+// Delete deletes the key
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	_, ok := m.data[key]
+	delete(m.data, key)
+	m.mu.Unlock()
+
+	if ok {
+		m.watchers.notify(service.StateEvent{Type: service.StateDeleted, Key: key})
+	}
+
+	return nil
+}
+
+// Keys returns the keys that start with the prefix
+func (m *Memory) Keys(_ context.Context, prefix string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0, len(m.data))
+
+	for k := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
+// Watch returns a channel that receives the changes made to the keys that start with the prefix until
+// the context is done. Events are dropped if the receiver falls more than DefaultWatchBuffer events behind.
+func (m *Memory) Watch(ctx context.Context, prefix string) (<-chan service.StateEvent, error) {
+	return m.watchers.add(ctx, prefix), nil
+}
+
+// snapshot returns a copy of the state
+func (m *Memory) snapshot() map[string][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := make(map[string][]byte, len(m.data))
+	for k, v := range m.data {
+		data[k] = v
+	}
+
+	return data
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	iostrings "gitlab.com/gobl/gobl/pkg/io/strings"
+	"gitlab.com/gobl/gobl/pkg/logger"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+const (
+	// DefaultRedisPrefix is the default prefix of the keys stored in Redis
+	DefaultRedisPrefix = "state"
+	// redisScanCount is the number of keys requested from Redis at a time when scanning
+	redisScanCount = 100
+)
+
+// Redis is a StateStore that keeps the state in Redis, so that it is shared between the replicas of a
+// service. Changes are published on a channel so that Watch receives the changes made by every replica.
+type Redis struct {
+	client  redis.UniversalClient
+	prefix  string
+	channel string
+}
+
+// deleteScript deletes the key and publishes the event on the channel if the key existed, so that watchers
+// are only told about keys that were deleted, as with the other stores
+//
+//nolint:gochecknoglobals
+var deleteScript = redis.NewScript(`
+if redis.call("DEL", KEYS[1]) == 1 then
+	redis.call("PUBLISH", ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// NewRedis creates a state store that keeps the keys in Redis under the prefix, and publishes the changes
+// on the channel <prefix>:events. Replicas that share state must use the same prefix, an empty prefix
+// is DefaultRedisPrefix.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+
+	return &Redis{
+		client:  client,
+		prefix:  prefix,
+		channel: prefix + ":events",
+	}
+}
+
+func (r *Redis) key(key string) string {
+	return r.prefix + ":" + key
+}
+
+// Get returns the value of the key, or service.ErrStateNotFound if it does not exist
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.client.Get(ctx, r.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%w: %s", service.ErrStateNotFound, key)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", key, err)
+	}
+
+	return v, nil
+}
+
+// Set sets the value of the key and publishes the change
+func (r *Redis) Set(ctx context.Context, key string, value []byte) error {
+	return r.change(ctx, service.StateEvent{Type: service.StateSet, Key: key, Value: value}, func(p redis.Pipeliner) {
+		p.Set(ctx, r.key(key), value, 0)
+	})
+}
+
+// Delete deletes the key and publishes the change if the key existed
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	msg, err := json.Marshal(service.StateEvent{Type: service.StateDeleted, Key: key})
+	if err != nil {
+		return fmt.Errorf("serialising state event: %w", err)
+	}
+
+	if err := deleteScript.Run(ctx, r.client, []string{r.key(key)}, r.channel, msg).Err(); err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// change makes the change and publishes the event in a single transaction
+func (r *Redis) change(ctx context.Context, e service.StateEvent, fn func(redis.Pipeliner)) error {
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("serialising state event: %w", err)
+	}
+
+	if _, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		fn(p)
+		p.Publish(ctx, r.channel, msg)
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("changing %s: %w", e.Key, err)
+	}
+
+	return nil
+}
+
+// Keys returns the keys that start with the prefix
+func (r *Redis) Keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	iter := r.client.Scan(ctx, 0, iostrings.EscapeGlob(r.key(prefix))+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), r.prefix+":"))
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scanning keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Watch subscribes to the changes made by every replica to the keys that start with the prefix until the
+// context is done. The subscription has been set up when Watch returns, so no later change is missed.
+// As with the other stores, events are dropped if the receiver falls more than DefaultWatchBuffer events behind,
+// so that a slow receiver does not hold up the subscription.
+func (r *Redis) Watch(ctx context.Context, prefix string) (<-chan service.StateEvent, error) {
+	sub := r.client.Subscribe(ctx, r.channel)
+
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, fmt.Errorf("subscribing to %s: %w", r.channel, err)
+	}
+
+	events := make(chan service.StateEvent, DefaultWatchBuffer)
+
+	go func() {
+		defer close(events)
+		defer sub.Close()
+
+		ch := sub.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var e service.StateEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+					logger.Logger().Warn("Ignoring invalid state event", zap.String("channel", r.channel), zap.Error(err))
+					continue
+				}
+
+				if !strings.HasPrefix(e.Key, prefix) {
+					continue
+				}
+
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				default:
+					logger.Logger().Warn("Dropping state event for slow watcher",
+						zap.String("key", e.Key), zap.Stringer("type", e.Type))
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
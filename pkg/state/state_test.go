@@ -0,0 +1,246 @@
+package state_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/gobl/gobl/pkg/service"
+	"gitlab.com/gobl/gobl/pkg/state"
+	gobltesting "gitlab.com/gobl/gobl/pkg/testing"
+)
+
+func setupRedis(t *testing.T) gobltesting.RedisScaffold {
+	t.Helper()
+
+	if os.Getenv("GITLAB_CI") != "" {
+		t.Skip("skipping Redis state tests on GitLab CI")
+	}
+
+	scaffold, err := gobltesting.SetupRedis(t, 10)
+	if err != nil {
+		t.Skipf("redis is not available: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := scaffold.Teardown(t); err != nil {
+			t.Logf("could not teardown Redis: %v", err)
+		}
+	})
+
+	return scaffold
+}
+
+func receive(t *testing.T, events <-chan service.StateEvent) service.StateEvent {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a state event")
+		return service.StateEvent{}
+	}
+}
+
+// testStore checks the behaviour every StateStore must have
+func testStore(t *testing.T, s service.StateStore) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Get should return ErrStateNotFound for a missing key", func(t *testing.T) {
+		_, err := s.Get(ctx, "missing")
+		assert.ErrorIs(t, err, service.ErrStateNotFound)
+	})
+
+	t.Run("Set should store the value", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "nodes/a", []byte("1")))
+
+		v, err := s.Get(ctx, "nodes/a")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1"), v)
+	})
+
+	t.Run("Keys should return the keys with the prefix", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "nodes/b", []byte("2")))
+		require.NoError(t, s.Set(ctx, "leader", []byte("a")))
+
+		keys, err := s.Keys(ctx, "nodes/")
+		require.NoError(t, err)
+		sort.Strings(keys)
+		assert.Equal(t, []string{"nodes/a", "nodes/b"}, keys)
+
+		keys, err = s.Keys(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, keys, 3)
+	})
+
+	t.Run("Delete should remove the key", func(t *testing.T) {
+		require.NoError(t, s.Delete(ctx, "leader"))
+		require.NoError(t, s.Delete(ctx, "leader"))
+
+		_, err := s.Get(ctx, "leader")
+		assert.ErrorIs(t, err, service.ErrStateNotFound)
+	})
+
+	t.Run("Watch should receive the changes to keys with the prefix", func(t *testing.T) {
+		wctx, wcancel := context.WithCancel(ctx)
+
+		events, err := s.Watch(wctx, "nodes/")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Set(ctx, "leader", []byte("b")))
+		require.NoError(t, s.Delete(ctx, "nodes/missing"))
+		require.NoError(t, s.Set(ctx, "nodes/c", []byte("3")))
+		require.NoError(t, s.Delete(ctx, "nodes/a"))
+
+		assert.Equal(t, service.StateEvent{Type: service.StateSet, Key: "nodes/c", Value: []byte("3")}, receive(t, events))
+		assert.Equal(t, service.StateEvent{Type: service.StateDeleted, Key: "nodes/a"}, receive(t, events))
+
+		wcancel()
+
+		assert.Eventually(t, func() bool {
+			select {
+			case _, ok := <-events:
+				return !ok
+			default:
+				return false
+			}
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Keys should match the characters of the prefix literally", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "glob*/a", []byte("1")))
+		require.NoError(t, s.Set(ctx, "globx/b", []byte("2")))
+
+		keys, err := s.Keys(ctx, "glob*")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"glob*/a"}, keys)
+
+		require.NoError(t, s.Delete(ctx, "glob*/a"))
+		require.NoError(t, s.Delete(ctx, "globx/b"))
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, state.NewMemory())
+
+	t.Run("Memory should copy the values it stores", func(t *testing.T) {
+		ctx := context.Background()
+		s := state.NewMemory()
+
+		v := []byte("a")
+		require.NoError(t, s.Set(ctx, "k", v))
+		v[0] = 'b'
+
+		got, err := s.Get(ctx, "k")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), got)
+	})
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+
+	s, err := state.NewFile(path)
+	require.NoError(t, err)
+
+	testStore(t, s)
+
+	t.Run("NewFile should load the state written to the file", func(t *testing.T) {
+		reloaded, err := state.NewFile(path)
+		require.NoError(t, err)
+
+		v, err := reloaded.Get(context.Background(), "nodes/c")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("3"), v)
+
+		_, err = reloaded.Get(context.Background(), "nodes/a")
+		assert.ErrorIs(t, err, service.ErrStateNotFound)
+	})
+
+	t.Run("NewFile should fail on an invalid file", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, os.WriteFile(invalid, []byte("{"), 0o600))
+
+		_, err := state.NewFile(invalid)
+		assert.Error(t, err)
+	})
+
+	t.Run("Set and Delete should not change the state if the file cannot be written", func(t *testing.T) {
+		ctx := context.Background()
+		dir := filepath.Join(t.TempDir(), "state")
+		path := filepath.Join(dir, "state.json")
+
+		s, err := state.NewFile(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Set(ctx, "kept", []byte("1")))
+
+		events, err := s.Watch(ctx, "")
+		require.NoError(t, err)
+
+		// replacing the directory with a file stops the state from being written
+		require.NoError(t, os.RemoveAll(dir))
+		require.NoError(t, os.WriteFile(dir, nil, 0o600))
+
+		require.Error(t, s.Set(ctx, "lost", []byte("2")))
+		require.Error(t, s.Delete(ctx, "kept"))
+
+		_, err = s.Get(ctx, "lost")
+		assert.ErrorIs(t, err, service.ErrStateNotFound)
+
+		v, err := s.Get(ctx, "kept")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1"), v)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Set should not leave temporary files next to the file", func(t *testing.T) {
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "state.json", entries[0].Name())
+	})
+}
+
+func TestRedis(t *testing.T) {
+	scaffold := setupRedis(t)
+
+	testStore(t, state.NewRedis(scaffold.Rdb, state.DefaultRedisPrefix))
+
+	t.Run("Watch should receive the changes made by another replica", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		a := state.NewRedis(scaffold.Rdb, "shared")
+		b := state.NewRedis(scaffold.Rdb, "shared")
+
+		events, err := a.Watch(ctx, "")
+		require.NoError(t, err)
+
+		require.NoError(t, b.Set(ctx, "leader", []byte("b")))
+
+		assert.Equal(t, service.StateEvent{Type: service.StateSet, Key: "leader", Value: []byte("b")}, receive(t, events))
+
+		v, err := a.Get(ctx, "leader")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("b"), v)
+	})
+	t.Run("NewRedis should use the default prefix for an empty prefix", func(t *testing.T) {
+		ctx := context.Background()
+
+		require.NoError(t, state.NewRedis(scaffold.Rdb, "").Set(ctx, "empty", []byte("1")))
+
+		v, err := state.NewRedis(scaffold.Rdb, state.DefaultRedisPrefix).Get(ctx, "empty")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("1"), v)
+	})
+}
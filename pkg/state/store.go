@@ -0,0 +1,9 @@
+package state
+
+import "gitlab.com/gobl/gobl/pkg/service"
+
+var (
+	_ service.StateStore = (*Memory)(nil)
+	_ service.StateStore = (*File)(nil)
+	_ service.StateStore = (*Redis)(nil)
+)
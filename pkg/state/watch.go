@@ -0,0 +1,69 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"gitlab.com/gobl/gobl/pkg/logger"
+	"gitlab.com/gobl/gobl/pkg/service"
+)
+
+// DefaultWatchBuffer is the number of events buffered for each watcher, events are dropped
+// if a watcher falls further behind than this
+const DefaultWatchBuffer = 64
+
+type watcher struct {
+	prefix string
+	ch     chan service.StateEvent
+}
+
+// watchers delivers the changes made through a store to the watchers in the same process
+type watchers struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+// add adds a watcher for the keys with the prefix, it is removed and its channel closed when the context is done
+func (w *watchers) add(ctx context.Context, prefix string) <-chan service.StateEvent {
+	wt := &watcher{prefix: prefix, ch: make(chan service.StateEvent, DefaultWatchBuffer)}
+
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[*watcher]struct{})
+	}
+	w.watchers[wt] = struct{}{}
+	w.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		w.mu.Lock()
+		delete(w.watchers, wt)
+		close(wt.ch)
+		w.mu.Unlock()
+	}()
+
+	return wt.ch
+}
+
+// notify sends the event to the watchers of the key without blocking
+func (w *watchers) notify(e service.StateEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for wt := range w.watchers {
+		if !strings.HasPrefix(e.Key, wt.prefix) {
+			continue
+		}
+
+		select {
+		case wt.ch <- e:
+		default:
+			logger.Logger().Warn("Dropping state event for slow watcher",
+				zap.String("key", e.Key), zap.Stringer("type", e.Type))
+		}
+	}
+}